@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"github.com/nkanaev/marc/site"
+)
+
+func runCheck(args []string) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	var output string
+	var opts site.CheckOptions
+	flags.StringVar(&output, "o", "", "output `directory` (default: <site>/public)")
+	flags.StringVar(&output, "output", "", "output `directory` (default: <site>/public)")
+	flags.BoolVar(&opts.External, "external", false, "also check the links to other sites")
+	flags.IntVar(&opts.Concurrency, "concurrency", 8, "`number` of sites checked at once")
+	flags.DurationVar(&opts.Delay, "delay", time.Second, "`delay` between requests to the same site")
+	flags.DurationVar(&opts.MaxAge, "max-age", 7*24*time.Hour, "`age` after which working links are checked again")
+	siteDir := parseArgs(flags, "check [flags] /path/to/site", args)
+
+	cfg := readConfig(siteDir, os.Getenv("MARC_ENV"))
+	check(site.New(siteDir, site.OutputDir(siteDir, output, cfg), cfg).Check(opts))
+}
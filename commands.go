@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nkanaev/marc/site"
+)
+
+// The exit codes of marc, besides 0 on success.
+const (
+	// exitError is for problems with the site: its content,
+	// templates or config.
+	exitError = 1
+	// exitUsage is for wrong command-line arguments.
+	exitUsage = 2
+	// exitIO is for failures reading or writing files.
+	exitIO = 3
+)
+
+var commands = map[string]func(args []string){
+	"build":  runBuild,
+	"serve":  runServe,
+	"new":    runNew,
+	"clean":  runClean,
+	"check":  runCheck,
+	"render": runRender,
+	"deploy": runDeploy,
+	"export": runExport,
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: %s <command> [flags] <path>
+
+commands:
+  build [flags] /path/to/site   build the site
+  serve [flags] /path/to/site   build the site and serve it locally
+  new [flags] section/page.md   create a new page from an archetype
+  clean [flags] /path/to/site   remove the output directory, or its stale files
+  check [flags] /path/to/site   check the links of the built site
+  render [flags] page.md        render one page to stdout, - for stdin
+  deploy [flags] /path/to/site  upload the built site to a deploy target
+  export [flags] /path/to/site  export a section as an EPUB or PDF book
+
+Run "%s <command> -h" to list the command's flags.
+`, os.Args[0], os.Args[0])
+}
+
+// parseArgs parses the command's flags and returns its only argument.
+func parseArgs(flags *flag.FlagSet, synopsis string, args []string) string {
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s %s\n", os.Args[0], synopsis)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+	return flags.Arg(0)
+}
+
+// check logs the error, if any, and exits
+// with the exit code of the error.
+func check(err error) {
+	if err == nil {
+		return
+	}
+	site.LogError(err)
+	os.Exit(exitCode(err))
+}
+
+// exitCode returns the exit code of an error: exitIO for failures
+// to read or write files, exitError otherwise.
+func exitCode(err error) int {
+	var siteErr *site.Error
+	if errors.As(err, &siteErr) && siteErr.IO {
+		return exitIO
+	}
+	return exitError
+}
+
+func readConfig(siteDir, env string) site.Config {
+	cfg, err := site.ReadConfigEnv(siteDir, env)
+	check(err)
+	return cfg
+}
+
+func runBuild(args []string) {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	var bf buildFlags
+	bf.register(flags)
+	siteDir := parseArgs(flags, "build [flags] /path/to/site", args)
+
+	ws, err := site.ReadWorkspace(siteDir)
+	check(err)
+	if ws != nil {
+		buildWorkspace(ws, flags, bf)
+		return
+	}
+	cfg := bf.config(siteDir)
+	outDir := site.OutputDir(siteDir, bf.output, cfg)
+	stopProfile := bf.profile()
+	s := site.New(siteDir, outDir, cfg)
+	err = s.Build()
+	stopProfile()
+	check(err)
+	if bf.watch {
+		check(s.Watch(func() {
+			rebuild(siteDir, outDir, bf)
+		}))
+	}
+}
+
+// rebuild builds the site again once changed, logging
+// the errors to let the site be fixed while watched.
+func rebuild(siteDir, outDir string, bf buildFlags) {
+	cfg, err := site.ReadConfigEnv(siteDir, bf.environment())
+	if err == nil {
+		bf.apply(&cfg)
+		err = site.New(siteDir, outDir, cfg).Build()
+	}
+	if err != nil {
+		site.LogError(err)
+	}
+}
+
+func runNew(args []string) {
+	flags := flag.NewFlagSet("new", flag.ExitOnError)
+	siteDir := flags.String("site", ".", "site `directory` the path is relative to")
+	path := parseArgs(flags, "new [-site dir] section/page.md", args)
+	check(site.NewPage(*siteDir, path))
+}
+
+func runRender(args []string) {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	siteDir := flags.String("site", ".", "site `directory` whose templates are used")
+	env := flags.String("env", "", "`environment` to render for (default: $MARC_ENV, or production)")
+	path := parseArgs(flags, "render [-site dir] page.md|-", args)
+
+	if *env == "" {
+		*env = os.Getenv("MARC_ENV")
+	}
+	cfg := readConfig(*siteDir, *env)
+	var text []byte
+	var err error
+	relpath := "stdin.md"
+	if path == "-" {
+		text, err = io.ReadAll(os.Stdin)
+	} else {
+		text, err = os.ReadFile(path)
+		relpath = filepath.Base(path)
+		// pages of the site keep their section and layout
+		if rel, relErr := filepath.Rel(*siteDir, path); relErr == nil && !strings.HasPrefix(rel, "..") {
+			relpath = rel
+		}
+	}
+	if err != nil {
+		site.LogError(err)
+		os.Exit(exitIO)
+	}
+	check(site.New(*siteDir, site.OutputDir(*siteDir, "", cfg), cfg).Convert(relpath, text, os.Stdout))
+}
+
+func runClean(args []string) {
+	flags := flag.NewFlagSet("clean", flag.ExitOnError)
+	var output string
+	var stale, dryRun bool
+	flags.StringVar(&output, "o", "", "output `directory` (default: <site>/public)")
+	flags.StringVar(&output, "output", "", "output `directory` (default: <site>/public)")
+	flags.BoolVar(&stale, "stale", false, "only remove the files the last build didn't write")
+	flags.BoolVar(&dryRun, "dry-run", false, "with -stale, log the files that would be removed, removing nothing")
+	siteDir := parseArgs(flags, "clean [flags] /path/to/site", args)
+
+	cfg := readConfig(siteDir, os.Getenv("MARC_ENV"))
+	s := site.New(siteDir, site.OutputDir(siteDir, output, cfg), cfg)
+	if stale {
+		check(s.CleanStale(dryRun))
+		return
+	}
+	check(s.Clean())
+}
+
+func runDeploy(args []string) {
+	flags := flag.NewFlagSet("deploy", flag.ExitOnError)
+	var output, target string
+	var dryRun bool
+	flags.StringVar(&output, "o", "", "output `directory` (default: <site>/public)")
+	flags.StringVar(&output, "output", "", "output `directory` (default: <site>/public)")
+	flags.StringVar(&target, "target", "", "`name` of the deploy target (default: the first)")
+	flags.BoolVar(&dryRun, "dry-run", false, "log the changes that would be made, uploading nothing")
+	siteDir := parseArgs(flags, "deploy [flags] /path/to/site", args)
+
+	cfg := readConfig(siteDir, os.Getenv("MARC_ENV"))
+	check(site.New(siteDir, site.OutputDir(siteDir, output, cfg), cfg).Deploy(target, dryRun))
+}
+
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	var opts site.ExportOptions
+	var output string
+	flags.StringVar(&opts.Format, "format", "epub", "book `format`: epub or pdf")
+	flags.StringVar(&opts.Section, "section", "", "`directory` of the pages exported (default: the whole site)")
+	flags.StringVar(&opts.PDFCommand, "pdf-command", "", "`command` printing the HTML on stdin to a PDF (default: weasyprint)")
+	flags.StringVar(&output, "o", "", "output `file` (default: <section>.<format>)")
+	siteDir := parseArgs(flags, "export [flags] /path/to/site", args)
+
+	if output == "" {
+		name := filepath.Base(filepath.Clean(opts.Section))
+		if opts.Section == "" || name == "." || name == string(filepath.Separator) {
+			abs, _ := filepath.Abs(siteDir)
+			name = filepath.Base(abs)
+		}
+		output = name + "." + opts.Format
+	}
+	cfg := readConfig(siteDir, os.Getenv("MARC_ENV"))
+	check(site.New(siteDir, site.OutputDir(siteDir, "", cfg), cfg).Export(output, opts))
+}
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
+	}
+	name, args := os.Args[1], os.Args[2:]
+	if name == "-h" || name == "-help" || name == "--help" || name == "help" {
+		usage()
+		return
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		// marc /path/to/site, as before there were commands
+		cmd, args = runBuild, os.Args[1:]
+	}
+	cmd(args)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nkanaev/marc/site"
+)
+
+// buildFlags holds the command-line flags shared by the commands
+// that build the site.
+type buildFlags struct {
+	output            string
+	watch             bool
+	drafts            bool
+	future            bool
+	expired           bool
+	force             bool
+	minify            bool
+	failOnBrokenLinks bool
+	strict            bool
+	quiet             bool
+	verbose           bool
+	logFormat         string
+	stats             string
+	dryRun            bool
+	clean             bool
+	env               string
+	offline           bool
+	cpuProfile        string
+	memProfile        string
+	trace             string
+	lowMemory         bool
+	// dev is set by serve, not by a flag.
+	dev bool
+}
+
+func (f *buildFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.output, "o", "", "output `directory` (default: <site>/public)")
+	flags.StringVar(&f.output, "output", "", "output `directory` (default: <site>/public)")
+	flags.BoolVar(&f.watch, "watch", false, "rebuild the site when files change")
+	flags.BoolVar(&f.drafts, "drafts", false, "include pages marked as drafts")
+	flags.BoolVar(&f.future, "future", false, "include pages dated in the future")
+	flags.BoolVar(&f.expired, "expired", false, "include pages past their expiry date")
+	flags.BoolVar(&f.force, "force", false, "rebuild all pages, ignoring the build cache")
+	flags.BoolVar(&f.minify, "minify", false, "minify HTML, CSS, JS, JSON, SVG and XML output")
+	flags.BoolVar(&f.strict, "strict", false, "fail the build if pages miss required front matter")
+	flags.BoolVar(&f.quiet, "quiet", false, "only log warnings and errors")
+	flags.BoolVar(&f.verbose, "verbose", false, "log details of the build")
+	flags.StringVar(&f.logFormat, "log-format", "text", "log `format`, text or json")
+	flags.StringVar(&f.stats, "stats", "", "write the build statistics as JSON to `file`")
+	flags.BoolVar(&f.dryRun, "dry-run", false, "log the output files that would be created or changed, writing nothing")
+	flags.BoolVar(&f.clean, "clean", false, "remove the output files the build no longer writes")
+	flags.StringVar(&f.env, "env", "", "`environment` to build for (default: $MARC_ENV, or production, development when serving)")
+	flags.BoolVar(&f.offline, "offline", false, "build with the copies of the remote sources, fetching nothing")
+	flags.BoolVar(&f.failOnBrokenLinks, "fail-on-broken-links", false, "fail the build if internal links are broken")
+	flags.BoolVar(&f.lowMemory, "low-memory", false, "keep only the summaries of the pages in memory, not their text and HTML")
+	flags.StringVar(&f.cpuProfile, "cpuprofile", "", "write a CPU profile of the build to `file`")
+	flags.StringVar(&f.memProfile, "memprofile", "", "write a heap profile to `file` once built")
+	flags.StringVar(&f.trace, "trace", "", "write an execution trace of the build to `file`")
+}
+
+// config reads the site config and applies the flags on top of it,
+// setting up the logs too.
+func (f *buildFlags) config(siteDir string) site.Config {
+	f.setLogging()
+	cfg := readConfig(siteDir, f.environment())
+	f.apply(&cfg)
+	return cfg
+}
+
+// setLogging sets up the logs as asked for by the flags.
+func (f *buildFlags) setLogging() {
+	level := site.Normal
+	switch {
+	case f.quiet:
+		level = site.Quiet
+	case f.verbose:
+		level = site.Verbose
+	}
+	if err := site.SetLogging(level, f.logFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUsage)
+	}
+}
+
+// environment returns the environment to build for: the one of -env,
+// or of MARC_ENV, or else development when serving and production
+// otherwise.
+func (f *buildFlags) environment() string {
+	if f.env != "" {
+		return f.env
+	}
+	if env := os.Getenv("MARC_ENV"); env != "" {
+		return env
+	}
+	if f.dev {
+		return site.Development
+	}
+	return site.Production
+}
+
+// apply applies the flags on top of the config.
+func (f *buildFlags) apply(cfg *site.Config) {
+	if f.drafts {
+		cfg.Drafts = true
+	}
+	if f.future {
+		cfg.Future = true
+	}
+	if f.expired {
+		cfg.Expired = true
+	}
+	if f.minify {
+		cfg.Minify = true
+	}
+	if f.lowMemory {
+		cfg.LowMemory = true
+	}
+	cfg.Force = f.force
+	cfg.Dev = f.dev
+	cfg.FailOnBrokenLinks = f.failOnBrokenLinks
+	cfg.Strict = f.strict
+	cfg.Stats = f.stats
+	cfg.DryRun = f.dryRun
+	cfg.Clean = f.clean
+	cfg.Offline = f.offline
+}
@@ -2,4 +2,17 @@ module github.com/nkanaev/marc
 
 go 1.18
 
-require github.com/yuin/goldmark v1.4.13
+require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/andybalholm/brotli v1.0.4
+	github.com/fsnotify/fsnotify v1.6.0
+	github.com/tdewolff/minify/v2 v2.12.4
+	github.com/tdewolff/parse/v2 v2.6.4
+	github.com/yuin/goldmark v1.4.13
+	github.com/yuin/goldmark-emoji v1.0.2
+	golang.org/x/image v0.5.0
+	golang.org/x/text v0.7.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.0.0-20220908164124-27713097b956 // indirect
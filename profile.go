@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+
+	"github.com/nkanaev/marc/site"
+)
+
+// profile starts the CPU profile and execution trace asked for by
+// the flags and returns the function stopping them, which writes
+// the heap profile too.
+func (f *buildFlags) profile() func() {
+	create := func(path string) *os.File {
+		file, err := os.Create(path)
+		if err != nil {
+			site.LogError(err)
+			os.Exit(exitIO)
+		}
+		return file
+	}
+	var stops []func()
+	if f.cpuProfile != "" {
+		file := create(f.cpuProfile)
+		if err := pprof.StartCPUProfile(file); err != nil {
+			site.LogError(err)
+			os.Exit(exitIO)
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			file.Close()
+		})
+	}
+	if f.trace != "" {
+		file := create(f.trace)
+		if err := trace.Start(file); err != nil {
+			site.LogError(err)
+			os.Exit(exitIO)
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			file.Close()
+		})
+	}
+	return func() {
+		for _, stop := range stops {
+			stop()
+		}
+		if f.memProfile == "" {
+			return
+		}
+		file := create(f.memProfile)
+		defer file.Close()
+		// up to date statistics of the memory in use
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(file); err != nil {
+			site.LogError(err)
+			os.Exit(exitIO)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sync"
+)
+
+const reloadPath = "/_marc/reload"
+
+// reloadScript is injected into pages served in watch mode.
+// It reloads the page once the server announces a rebuild.
+const reloadScript = `<script>new EventSource("` + reloadPath + `").onmessage = function() { location.reload() }</script>`
+
+// reloader notifies connected browsers about rebuilds
+// using server-sent events.
+type reloader struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+func newReloader() *reloader {
+	return &reloader{clients: make(map[chan struct{}]bool)}
+}
+
+func (rl *reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	ch := make(chan struct{}, 1)
+	rl.mu.Lock()
+	rl.clients[ch] = true
+	rl.mu.Unlock()
+	defer func() {
+		rl.mu.Lock()
+		delete(rl.clients, ch)
+		rl.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ch:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+func (rl *reloader) reload() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	for ch := range rl.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// injectReload serves html files from root with the reload script
+// added to them. The files on disk are left untouched.
+func injectReload(root http.Dir, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name := req.URL.Path
+		if len(name) > 0 && name[len(name)-1] == '/' {
+			name += "index.html"
+		}
+		if path.Ext(name) != ".html" {
+			next.ServeHTTP(w, req)
+			return
+		}
+		f, err := root.Open(name)
+		if err != nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+		defer f.Close()
+		stat, err := f.Stat()
+		if err != nil || stat.IsDir() {
+			next.ServeHTTP(w, req)
+			return
+		}
+		body, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		script := []byte(reloadScript)
+		if i := bytes.LastIndex(body, []byte("</body>")); i != -1 {
+			body = append(body[:i:i], append(script, body[i:]...)...)
+		} else {
+			body = append(body, script...)
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeContent(w, req, name, stat.ModTime(), bytes.NewReader(body))
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/nkanaev/marc/site"
+)
+
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	var bf buildFlags
+	bf.register(flags)
+	bf.dev = true
+	port := flags.Int("port", 8080, "`port` to listen on")
+	useTLS := flags.Bool("tls", false, "serve HTTPS with a certificate for localhost")
+	certFile := flags.String("cert", "", "certificate `file` for -tls (default: made for localhost)")
+	keyFile := flags.String("key", "", "key `file` of the -cert certificate")
+	siteDir := parseArgs(flags, "serve [flags] /path/to/site", args)
+
+	cfg := bf.config(siteDir)
+	outDir := site.OutputDir(siteDir, bf.output, cfg)
+	stopProfile := bf.profile()
+	s := site.New(siteDir, outDir, cfg)
+	err := s.Build()
+	stopProfile()
+	check(err)
+
+	server := &http.Server{Addr: fmt.Sprintf("localhost:%d", *port)}
+	scheme := "http"
+	if *useTLS || *certFile != "" {
+		cert, err := devCertificate(*certFile, *keyFile)
+		if err != nil {
+			site.LogError(err)
+			os.Exit(exitIO)
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		scheme = "https"
+	}
+	listen := func(handler http.Handler) error {
+		server.Handler = handler
+		if server.TLSConfig != nil {
+			return server.ListenAndServeTLS("", "")
+		}
+		return server.ListenAndServe()
+	}
+	log.Printf("serving %s at %s://%s/", outDir, scheme, server.Addr)
+	root := http.Dir(outDir)
+	if !bf.watch {
+		check(listen(http.FileServer(root)))
+	}
+
+	rl := newReloader()
+	mux := http.NewServeMux()
+	mux.Handle(reloadPath, rl)
+	mux.Handle("/", injectReload(root, http.FileServer(root)))
+	go func() {
+		check(listen(mux))
+	}()
+	check(s.Watch(func() {
+		rebuild(siteDir, outDir, bf)
+		rl.reload()
+	}))
+}
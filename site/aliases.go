@@ -0,0 +1,57 @@
+package site
+
+import (
+	"bytes"
+	"html/template"
+	"path/filepath"
+	"strings"
+)
+
+var aliasTmpl = template.Must(template.New("alias").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>{{ . }}</title>
+    <link rel="canonical" href="{{ . }}">
+    <meta name="robots" content="noindex">
+    <meta http-equiv="refresh" content="0; url={{ . }}">
+</head>
+<body>
+    <a href="{{ . }}">{{ . }}</a>
+</body>
+</html>
+`))
+
+// aliasPath returns the output file of an alias: the file itself
+// for /old.html, or index.html in the directory for /old/.
+func aliasPath(outDir, alias string) string {
+	path := filepath.Join(outDir, filepath.FromSlash(strings.TrimPrefix(alias, "/")))
+	if strings.HasSuffix(alias, "/") || filepath.Ext(path) == "" {
+		path = filepath.Join(path, "index.html")
+	}
+	return path
+}
+
+// pageAliases returns the aliases listed in the page's front matter.
+func pageAliases(page Page) []string {
+	return metaList(page.Meta["aliases"])
+}
+
+// writeAliases writes a redirecting page for each of the aliases
+// of the pages.
+func (s *Site) writeAliases(outDir string, cfg Config, pages Pages) {
+	var buf bytes.Buffer
+	for _, page := range pages {
+		target := s.relURL(page.Url)
+		if cfg.BaseURL != "" {
+			target = cfg.absURL(page.Url)
+		}
+		for _, alias := range pageAliases(page) {
+			buf.Reset()
+			if err := aliasTmpl.Execute(&buf, target); err != nil {
+				fatal("failed to render alias:", err)
+			}
+			s.writeFile(aliasPath(outDir, alias), buf.Bytes())
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package site
+
+import (
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+// anchorRenderer renders headings with an id followed by
+// a link to themselves, for readers to copy.
+type anchorRenderer struct {
+	symbol string
+}
+
+func (r *anchorRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindHeading, r.renderHeading)
+}
+
+func (r *anchorRenderer) renderHeading(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.Heading)
+	if entering {
+		w.WriteString("<h")
+		w.WriteByte("0123456"[n.Level])
+		if n.Attributes() != nil {
+			html.RenderAttributes(w, node, html.HeadingAttributeFilter)
+		}
+		w.WriteByte('>')
+		return ast.WalkContinue, nil
+	}
+	if id, ok := n.AttributeString("id"); ok {
+		w.WriteString(` <a class="anchor" href="#`)
+		w.Write(util.EscapeHTML(id.([]byte)))
+		w.WriteString(`" aria-hidden="true">`)
+		w.Write(util.EscapeHTML([]byte(r.symbol)))
+		w.WriteString("</a>")
+	}
+	w.WriteString("</h")
+	w.WriteByte("0123456"[n.Level])
+	w.WriteString(">\n")
+	return ast.WalkContinue, nil
+}
+
+type anchorExtension struct {
+	symbol string
+}
+
+func (e *anchorExtension) Extend(m goldmark.Markdown) {
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&anchorRenderer{symbol: e.symbol}, 500)),
+	)
+}
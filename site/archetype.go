@@ -0,0 +1,84 @@
+package site
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const archetypeDir = "archetypes"
+
+const defaultArchetype = `---
+title: {{ .Title }}
+date: {{ .Date }}
+draft: true
+---
+`
+
+// newPage creates a content file from the archetype of its section,
+// falling back to archetypes/default.md and then the built-in one.
+func (s *Site) newPage(siteDir, relpath string) {
+	path := filepath.Join(siteDir, relpath)
+	if _, err := os.Stat(path); err == nil {
+		fatalf("%s already exists", path)
+	}
+
+	section := ""
+	if parts := strings.SplitN(filepath.ToSlash(relpath), "/", 2); len(parts) == 2 {
+		section = parts[0]
+	}
+	archetype := defaultArchetype
+	for _, name := range []string{section + ".md", "default.md"} {
+		if name == ".md" {
+			continue
+		}
+		text, err := os.ReadFile(filepath.Join(siteDir, archetypeDir, name))
+		if err == nil {
+			archetype = string(text)
+			break
+		}
+		if !os.IsNotExist(err) {
+			fatalIO("failed to read archetype:", err)
+		}
+	}
+
+	tmpl, err := template.New("archetype").Parse(archetype)
+	if err != nil {
+		fatal("failed to parse archetype:", err)
+	}
+	name := strings.TrimSuffix(filepath.Base(relpath), filepath.Ext(relpath))
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]string{
+		"Title":   titleFromName(name),
+		"Date":    time.Now().Format("2006-01-02"),
+		"Section": section,
+	})
+	if err != nil {
+		fatal("failed to render archetype:", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fatalIO("failed to create directory:", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		fatalIO("failed to write file:", err)
+	}
+	s.logOutput(path)
+}
+
+// titleFromName turns a file name like "my-first-post" into "My First Post".
+func titleFromName(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || r == ' '
+	})
+	for i, word := range words {
+		r, size := utf8.DecodeRuneInString(word)
+		words[i] = string(unicode.ToUpper(r)) + word[size:]
+	}
+	return strings.Join(words, " ")
+}
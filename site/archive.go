@@ -0,0 +1,67 @@
+package site
+
+import (
+	"bytes"
+	"html/template"
+	"path/filepath"
+)
+
+// ArchiveConfig controls the archive pages.
+type ArchiveConfig struct {
+	Enabled bool `toml:"enabled" yaml:"enabled"`
+	// Section limits the archive to the pages of a section.
+	Section string `toml:"section" yaml:"section"`
+	// Years and Months add a page for every year and month.
+	Years  bool `toml:"years" yaml:"years"`
+	Months bool `toml:"months" yaml:"months"`
+}
+
+// writeArchive renders the dated pages grouped by month to
+// archive/index.html, and optionally the pages of each year
+// and month to 2006/index.html and 2006/01/index.html.
+func (s *Site) writeArchive(outDir string, cfg Config, tmpl *template.Template, pages Pages, tags map[string]Pages, data map[string]interface{}) {
+	if !cfg.Archive.Enabled {
+		return
+	}
+	var dated Pages
+	for _, page := range pages {
+		if page.isIndex() || page.isNotFound() {
+			continue
+		}
+		if cfg.Archive.Section != "" && page.Section != cfg.Archive.Section {
+			continue
+		}
+		if _, err := page.date(); err == nil {
+			dated = append(dated, page)
+		}
+	}
+
+	var buf bytes.Buffer
+	renderArchive := func(url, title string, pages Pages) {
+		page := Page{
+			Meta: map[string]interface{}{"title": title},
+			Url:  url,
+		}
+		body := s.render(&buf, tmpl, map[string]interface{}{
+			"Page":   page,
+			"Pages":  pages,
+			"Groups": groupBy("month", pages),
+			"Tags":   tags,
+			"Site":   cfg,
+			"Data":   data,
+		})
+		s.writeFile(filepath.Join(outDir, filepath.FromSlash(url), "index.html"), body)
+	}
+
+	renderArchive("archive/", "Archive", dated)
+	if cfg.Archive.Years {
+		for _, group := range groupBy("year", dated) {
+			renderArchive(group.Key+"/", group.Key, group.Pages)
+		}
+	}
+	if cfg.Archive.Months {
+		for _, group := range groupBy("month", dated) {
+			renderArchive(group.Key[:4]+"/"+group.Key[5:]+"/", group.Key, group.Pages)
+		}
+	}
+}
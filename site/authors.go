@@ -0,0 +1,146 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Author describes an author of the site, from the authors config
+// or the _data/authors or data/authors files.
+type Author struct {
+	// ID is the key of the author in the config or data files,
+	// used in the author and authors front matter fields.
+	ID     string            `toml:"-" yaml:"-"`
+	Name   string            `toml:"name" yaml:"name"`
+	Bio    string            `toml:"bio" yaml:"bio"`
+	Avatar string            `toml:"avatar" yaml:"avatar"`
+	Links  map[string]string `toml:"links" yaml:"links"`
+	// Url is the url of the author's page, if enabled.
+	Url string `toml:"-" yaml:"-"`
+}
+
+// authorURL returns the url of the page of an author.
+func authorURL(id string) string {
+	return "authors/" + slugify(id) + "/"
+}
+
+// setAuthors sets the authors of the pages from their authors
+// front matter field, or else their author one. Authors are
+// described by the authors config, or else by the data files of
+// _data/authors or data/authors (.Data.authors.<id>), those
+// missing from both being named after the field.
+func setAuthors(pages Pages, cfg Config, data map[string]interface{}) {
+	known := make(map[string]*Author)
+	for i := range pages {
+		ids := metaList(pages[i].Meta["authors"])
+		if len(ids) == 0 {
+			if id := pages[i].metaString("author"); id != "" {
+				ids = []string{id}
+			}
+		}
+		pages[i].Authors = nil
+		for _, id := range ids {
+			author, ok := known[id]
+			if !ok {
+				author = findAuthor(id, cfg, data)
+				known[id] = author
+			}
+			pages[i].Authors = append(pages[i].Authors, author)
+		}
+		if len(pages[i].Authors) > 0 {
+			pages[i].Author = pages[i].Authors[0]
+		}
+	}
+}
+
+// findAuthor returns the author of the id, from the config
+// or else the data files.
+func findAuthor(id string, cfg Config, data map[string]interface{}) *Author {
+	if author, ok := cfg.Authors[id]; ok {
+		return author
+	}
+	author := &Author{ID: id, Name: id}
+	authors, _ := data["authors"].(map[string]interface{})
+	if fields, ok := authors[id].(map[string]interface{}); ok {
+		// read like front matter
+		entry := Page{Meta: fields}
+		if name := entry.metaString("name"); name != "" {
+			author.Name = name
+		}
+		author.Bio = entry.metaString("bio")
+		author.Avatar = entry.metaString("avatar")
+		if links, ok := fields["links"].(map[string]interface{}); ok {
+			author.Links = make(map[string]string, len(links))
+			for name, url := range links {
+				author.Links[name] = fmt.Sprint(url)
+			}
+		}
+	}
+	if cfg.AuthorPages {
+		author.Url = authorURL(id)
+	}
+	return author
+}
+
+// authorEntry is an author with their pages, listed on the authors page.
+type authorEntry struct {
+	*Author
+	Pages Pages
+}
+
+// writeAuthors renders the list of authors to authors/index.html
+// and the pages of each author to authors/<author>/index.html.
+func (s *Site) writeAuthors(outDir string, cfg Config, tmpl *template.Template, pages Pages, tags map[string]Pages, data map[string]interface{}) {
+	if !cfg.AuthorPages {
+		return
+	}
+	byID := make(map[string]*authorEntry)
+	var authors []*authorEntry
+	for _, page := range pages {
+		for _, author := range page.Authors {
+			entry, ok := byID[author.ID]
+			if !ok {
+				entry = &authorEntry{Author: author}
+				byID[author.ID] = entry
+				authors = append(authors, entry)
+			}
+			entry.Pages = append(entry.Pages, page)
+		}
+	}
+	if len(authors) == 0 {
+		return
+	}
+	sort.Slice(authors, func(i, j int) bool {
+		if name, other := strings.ToLower(authors[i].Name), strings.ToLower(authors[j].Name); name != other {
+			return name < other
+		}
+		return authors[i].ID < authors[j].ID
+	})
+
+	var buf bytes.Buffer
+	renderAuthor := func(url, title string, author *Author, pages Pages) {
+		page := Page{
+			Meta: map[string]interface{}{"title": title},
+			Url:  url,
+		}
+		body := s.render(&buf, tmpl, map[string]interface{}{
+			"Page":    page,
+			"Pages":   pages,
+			"Author":  author,
+			"Authors": authors,
+			"Tags":    tags,
+			"Site":    cfg,
+			"Data":    data,
+		})
+		s.writeFile(filepath.Join(outDir, filepath.FromSlash(url), "index.html"), body)
+	}
+
+	renderAuthor("authors/", "Authors", nil, pages)
+	for _, entry := range authors {
+		renderAuthor(entry.Url, entry.Name, entry.Author, entry.Pages)
+	}
+}
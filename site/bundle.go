@@ -0,0 +1,123 @@
+package site
+
+import (
+	"mime"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Resource is a file of a page bundle: a directory with an index.md,
+// whose other files belong to the page and are written next to it.
+type Resource struct {
+	// Name is the path of the file in the bundle, e.g. images/cover.jpg.
+	Name string
+	// Url is the site-relative url of the file.
+	Url string
+	// MediaType is the media type of the file's extension, if known.
+	MediaType string
+}
+
+// Resources are the files of a page bundle, sorted by name.
+type Resources []Resource
+
+// Get returns the resource of the name, or nil if there's none.
+func (r Resources) Get(name string) *Resource {
+	for i := range r {
+		if r[i].Name == name {
+			return &r[i]
+		}
+	}
+	return nil
+}
+
+// Match returns the resources whose name matches the pattern,
+// as in path.Match, e.g. {{ range .Page.Resources.Match "*.jpg" }}.
+func (r Resources) Match(pattern string) Resources {
+	var matched Resources
+	for _, res := range r {
+		if ok, _ := path.Match(pattern, res.Name); ok {
+			matched = append(matched, res)
+		}
+	}
+	return matched
+}
+
+// ByType returns the resources of a media type, such as image/png,
+// or of a main type, such as image.
+func (r Resources) ByType(mediaType string) Resources {
+	var matched Resources
+	for _, res := range r {
+		main, _, _ := strings.Cut(res.MediaType, "/")
+		if res.MediaType == mediaType || main == mediaType {
+			matched = append(matched, res)
+		}
+	}
+	return matched
+}
+
+// isBundle reports whether the page is the index.md of
+// a page bundle, a directory other than the site root.
+func (p Page) isBundle() bool {
+	return p.filename() == "index" && filepath.Dir(p.RelPath) != "."
+}
+
+// setResources sets the resources of the page bundles: the static
+// files under their directory but not under a nested bundle. Pages
+// written elsewhere than their directory, such as translations,
+// get copies of the files next to them.
+func setResources(pages Pages, assets map[string]string) {
+	bundles := make(map[string]bool)
+	for _, page := range pages {
+		if page.isBundle() {
+			bundles[filepath.Dir(page.RelPath)] = true
+		}
+	}
+	names := make([]string, 0, len(assets))
+	for name := range assets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i := range pages {
+		if !pages[i].isBundle() {
+			continue
+		}
+		dir := filepath.Dir(pages[i].RelPath)
+		urlDir := pages[i].Url
+		if !strings.HasSuffix(urlDir, "/") {
+			if urlDir = path.Dir(urlDir) + "/"; urlDir == "./" {
+				urlDir = ""
+			}
+		}
+		copied := urlDir != filepath.ToSlash(dir)+"/"
+		var resources Resources
+		for _, name := range names {
+			if !isWithin(name, dir) || bundleOf(name, bundles) != dir {
+				continue
+			}
+			rel := filepath.ToSlash(strings.TrimPrefix(name, dir+string(filepath.Separator)))
+			res := Resource{
+				Name:      rel,
+				Url:       urlDir + rel,
+				MediaType: mime.TypeByExtension(path.Ext(rel)),
+			}
+			if copied {
+				assets[filepath.FromSlash(res.Url)] = assets[name]
+			}
+			resources = append(resources, res)
+		}
+		pages[i].Resources = resources
+	}
+}
+
+// bundleOf returns the directory of the innermost bundle
+// the file is in, or "" if none.
+func bundleOf(name string, bundles map[string]bool) string {
+	for dir := filepath.Dir(name); dir != "."; dir = filepath.Dir(dir) {
+		if bundles[dir] {
+			return dir
+		}
+	}
+	return ""
+}
@@ -0,0 +1,183 @@
+package site
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+)
+
+const cacheFile = ".marc-cache.json"
+
+// buildCache remembers the content hashes from the previous build,
+// so that unchanged pages and assets don't have to be written again.
+//
+// Site covers everything a page may depend on besides its own text
+// and the templates and data files it is rendered with (see tmplDeps):
+// config, other files, and the front matter of all pages. If it changes,
+// the whole site is rebuilt, as it is when a file whose fingerprinted
+// name was used, or that templates read, in the previous build has
+// changed.
+type buildCache struct {
+	Site         string            `json:"site"`
+	Files        map[string]string `json:"files"`
+	Fingerprints map[string]string `json:"fingerprints"`
+	// Includes are the hashes of the files read by templates.
+	Includes map[string]string `json:"includes"`
+	// Outputs are the files of the output the build wrote
+	// or left unchanged, relative to it.
+	Outputs []string `json:"outputs"`
+
+	site *Site
+	prev *buildCache
+	mu   sync.Mutex
+}
+
+// linkedHash returns the hash of the url and text of the pages
+// linked to the page as Prev, Next, Related, Backlinks and the like,
+// since its template may show their summaries or reading times.
+func linkedHash(page Page) string {
+	var chunks [][]byte
+	add := func(other *Page) {
+		if other == nil {
+			chunks = append(chunks, nil)
+			return
+		}
+		chunks = append(chunks, []byte(other.Url), []byte(other.textHash), []byte(other.LastMod.String()))
+	}
+	for _, other := range []*Page{page.Prev, page.Next, page.PrevInSection, page.NextInSection} {
+		add(other)
+	}
+	for _, list := range [][]*Page{page.Related, page.Backlinks, page.Translations} {
+		for _, other := range list {
+			add(other)
+		}
+		chunks = append(chunks, nil)
+	}
+	if page.Series != nil {
+		for _, other := range page.Series.Pages {
+			add(other)
+		}
+	}
+	return hashBytes(chunks...)
+}
+
+func hashBytes(chunks ...[]byte) string {
+	h := sha256.New()
+	for _, chunk := range chunks {
+		h.Write(chunk)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newBuildCache computes the site hash and loads the previous cache
+// from outDir unless a full rebuild is forced.
+func (s *Site) newBuildCache(outDir string, cfg Config, deps []string, pages Pages) *buildCache {
+	var chunks [][]byte
+	cfgJSON, err := json.Marshal(cfg)
+	if err != nil {
+		fatal("failed to hash config:", err)
+	}
+	chunks = append(chunks, cfgJSON)
+	for _, path := range deps {
+		text, err := s.readSource(path)
+		if err != nil {
+			fatalIO("failed to read ", err)
+		}
+		chunks = append(chunks, []byte(path), text)
+	}
+	for _, page := range pages {
+		meta, err := json.Marshal(page.Meta)
+		if err != nil {
+			fatal("failed to hash front matter:", err)
+		}
+		chunks = append(chunks, []byte(page.RelPath), meta)
+	}
+
+	cache := &buildCache{
+		Site:  hashBytes(chunks...),
+		Files: make(map[string]string),
+		site:  s,
+	}
+	if cfg.Force {
+		return cache
+	}
+	text, err := s.readOutput(filepath.Join(outDir, cacheFile))
+	if err != nil {
+		return cache
+	}
+	var prev buildCache
+	if err := json.Unmarshal(text, &prev); err == nil && prev.Site == cache.Site &&
+		!s.fingerprints.changed(prev.Fingerprints) && !s.includes.changed(prev.Includes) {
+		cache.prev = &prev
+	}
+	return cache
+}
+
+// unchanged records the hash of the source and reports whether
+// it is the same as in the previous build and the output still exists.
+func (c *buildCache) unchanged(relpath, hash, outPath string) bool {
+	c.mu.Lock()
+	c.Files[relpath] = hash
+	c.mu.Unlock()
+	if c.prev == nil || c.prev.Files[relpath] != hash {
+		return false
+	}
+	if _, err := c.site.statOutput(outPath); err != nil {
+		return false
+	}
+	c.site.keepOutput(outPath)
+	c.site.logDebug("= %s unchanged", outPath)
+	return true
+}
+
+// forget drops the hash of a file failing to build,
+// to have it built again next time.
+func (c *buildCache) forget(relpath string) {
+	c.mu.Lock()
+	delete(c.Files, relpath)
+	c.mu.Unlock()
+}
+
+// keepFingerprints records the fingerprinted files of the previous
+// build as outputs, the pages skipped in this build still using them.
+func (c *buildCache) keepFingerprints(outDir string) {
+	if c.prev == nil {
+		return
+	}
+	for _, hashed := range c.prev.Fingerprints {
+		c.site.keepOutput(filepath.Join(outDir, filepath.FromSlash(hashed)))
+	}
+}
+
+func (c *buildCache) write(outDir string) {
+	// pages skipped in this build still use the previous fingerprints
+	c.Fingerprints = make(map[string]string)
+	if c.prev != nil {
+		for name, hashed := range c.prev.Fingerprints {
+			c.Fingerprints[name] = hashed
+		}
+	}
+	for name, hashed := range c.site.fingerprints.names {
+		c.Fingerprints[name] = hashed
+	}
+	c.Includes = make(map[string]string)
+	if c.prev != nil {
+		for name, hash := range c.prev.Includes {
+			c.Includes[name] = hash
+		}
+	}
+	for name, hash := range c.site.includes.hashes {
+		c.Includes[name] = hash
+	}
+	c.Outputs = c.site.outputs.names(outDir)
+	text, err := json.Marshal(c)
+	if err != nil {
+		fatalIO("failed to save build cache:", err)
+	}
+	if err := c.site.writeOutput(filepath.Join(outDir, cacheFile), text); err != nil {
+		fatalIO("failed to save build cache:", err)
+	}
+}
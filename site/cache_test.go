@@ -0,0 +1,132 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashBytes(t *testing.T) {
+	if hashBytes([]byte("a"), []byte("b")) != hashBytes([]byte("a"), []byte("b")) {
+		t.Error("hashBytes is not deterministic")
+	}
+	tests := [][2][][]byte{
+		{{[]byte("ab"), []byte("c")}, {[]byte("a"), []byte("bc")}},
+		{{[]byte("a")}, {[]byte("a"), nil}},
+		{{}, {nil}},
+	}
+	for _, test := range tests {
+		if hashBytes(test[0]...) == hashBytes(test[1]...) {
+			t.Errorf("hashBytes(%q) == hashBytes(%q)", test[0], test[1])
+		}
+	}
+}
+
+func TestBuildCache(t *testing.T) {
+	type site struct {
+		cfg   Config
+		dep   string
+		pages Pages
+	}
+	base := func() site {
+		return site{
+			cfg: Config{Title: "Site"},
+			dep: "<html>{{ .Page.HTML }}</html>",
+			pages: Pages{
+				{RelPath: "a.md", Meta: map[string]interface{}{"title": "A"}},
+				{RelPath: "b.md", Meta: map[string]interface{}{"title": "B"}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		change    func(*site)
+		hash      string
+		unchanged bool
+	}{
+		{name: "nothing", change: func(*site) {}, hash: "a1", unchanged: true},
+		{name: "page text", change: func(*site) {}, hash: "a2"},
+		{name: "config", change: func(s *site) { s.cfg.Title = "Other" }, hash: "a1"},
+		{name: "forced", change: func(s *site) { s.cfg.Force = true }, hash: "a1"},
+		{name: "template", change: func(s *site) { s.dep = "<html></html>" }, hash: "a1"},
+		{name: "front matter of another page", change: func(s *site) { s.pages[1].Meta["title"] = "C" }, hash: "a1"},
+		{name: "new page", change: func(s *site) { s.pages = append(s.pages, Page{RelPath: "c.md"}) }, hash: "a1"},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		outDir := filepath.Join(dir, "public")
+		outPath := filepath.Join(outDir, "a.html")
+		dep := filepath.Join(dir, "base.tmpl")
+		if err := os.MkdirAll(outDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		s := newSite(os.DirFS(dir), dir, true, DirOutput(outDir), outDir, Config{})
+		if err := os.WriteFile(outPath, []byte("a"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		state := base()
+		if err := os.WriteFile(dep, []byte(state.dep), 0644); err != nil {
+			t.Fatal(err)
+		}
+		first := s.newBuildCache(outDir, state.cfg, []string{dep}, state.pages)
+		if first.unchanged("a.md", "a1", outPath) {
+			t.Errorf("%s: unchanged without a previous build", test.name)
+		}
+		first.write(outDir)
+
+		test.change(&state)
+		if err := os.WriteFile(dep, []byte(state.dep), 0644); err != nil {
+			t.Fatal(err)
+		}
+		second := s.newBuildCache(outDir, state.cfg, []string{dep}, state.pages)
+		if got := second.unchanged("a.md", test.hash, outPath); got != test.unchanged {
+			t.Errorf("%s: unchanged = %v, want %v", test.name, got, test.unchanged)
+		}
+		if second.Files["a.md"] != test.hash {
+			t.Errorf("%s: recorded hash %q, want %q", test.name, second.Files["a.md"], test.hash)
+		}
+
+		// the output must still be there
+		if err := os.Remove(outPath); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dep, []byte(base().dep), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if s.newBuildCache(outDir, base().cfg, []string{dep}, base().pages).unchanged("a.md", "a1", outPath) {
+			t.Errorf("%s: unchanged without its output", test.name)
+		}
+	}
+}
+
+func TestLinkedHash(t *testing.T) {
+	base := func() Page {
+		prev := &Page{Url: "a/", textHash: "a1"}
+		next := &Page{Url: "c/", textHash: "c1"}
+		related := &Page{Url: "d/", textHash: "d1"}
+		return Page{Url: "b/", textHash: "b1", Prev: prev, Next: next, Related: []*Page{related}}
+	}
+	tests := []struct {
+		name    string
+		change  func(*Page)
+		changed bool
+	}{
+		{name: "nothing", change: func(*Page) {}},
+		{name: "own text", change: func(p *Page) { p.textHash = "b2" }},
+		{name: "prev text", change: func(p *Page) { p.Prev.textHash = "a2" }, changed: true},
+		{name: "next url", change: func(p *Page) { p.Next.Url = "e/" }, changed: true},
+		{name: "related text", change: func(p *Page) { p.Related[0].textHash = "d2" }, changed: true},
+		{name: "no prev", change: func(p *Page) { p.Prev = nil }, changed: true},
+		{name: "related as backlink", change: func(p *Page) { p.Backlinks, p.Related = p.Related, nil }, changed: true},
+		{name: "series", change: func(p *Page) { p.Series = &Series{Pages: []*Page{p.Next}} }, changed: true},
+	}
+	for _, test := range tests {
+		page := base()
+		test.change(&page)
+		if got := linkedHash(page) != linkedHash(base()); got != test.changed {
+			t.Errorf("%s: changed = %v, want %v", test.name, got, test.changed)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// CardConfig controls the social card images.
+type CardConfig struct {
+	Enabled bool `toml:"enabled" yaml:"enabled"`
+	// Background is a color such as "#1e293b", or the path
+	// of a PNG or JPEG image relative to the site directory.
+	Background string `toml:"background" yaml:"background"`
+	Color      string `toml:"color" yaml:"color"`
+}
+
+const (
+	cardWidth  = 1200
+	cardHeight = 630
+	cardMargin = 80
+	cardLines  = 4
+)
+
+// cardURL returns the url of the social card of a page,
+// next to the page: card.png in its directory, or
+// posts/hello/card.png for posts/hello.html.
+func cardURL(url string) string {
+	if url != "" && !strings.HasSuffix(url, "/") {
+		url = strings.TrimSuffix(url, ".html") + "/"
+	}
+	return url + "card.png"
+}
+
+// cardRenderer draws social cards. It isn't safe for concurrent use.
+type cardRenderer struct {
+	background image.Image
+	color      color.Color
+	title      font.Face
+	site       font.Face
+}
+
+func (s *Site) newCardRenderer(siteDir string, cfg CardConfig) (*cardRenderer, error) {
+	r := &cardRenderer{
+		background: image.NewUniform(color.RGBA{0x1e, 0x29, 0x3b, 0xff}),
+		color:      color.White,
+	}
+	if cfg.Color != "" {
+		c, err := parseColor(cfg.Color)
+		if err != nil {
+			return nil, err
+		}
+		r.color = c
+	}
+	if strings.HasPrefix(cfg.Background, "#") {
+		c, err := parseColor(cfg.Background)
+		if err != nil {
+			return nil, err
+		}
+		r.background = image.NewUniform(c)
+	} else if cfg.Background != "" {
+		f, err := s.openSource(filepath.Join(siteDir, cfg.Background))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		img, _, err := image.Decode(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", cfg.Background, err)
+		}
+		r.background = img
+	}
+
+	var err error
+	if r.title, err = newFace(gobold.TTF, 64); err != nil {
+		return nil, err
+	}
+	if r.site, err = newFace(goregular.TTF, 32); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func newFace(ttf []byte, size float64) (font.Face, error) {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		return nil, err
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// render draws the title of a page over the background,
+// with the site title at the bottom, and returns it as a PNG.
+func (r *cardRenderer) render(title, site string) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+	if _, ok := r.background.(*image.Uniform); ok {
+		draw.Draw(img, img.Bounds(), r.background, image.Point{}, draw.Src)
+	} else {
+		draw.CatmullRom.Scale(img, img.Bounds(), r.background, r.background.Bounds(), draw.Src, nil)
+	}
+
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(r.color), Face: r.title}
+	lineHeight := r.title.Metrics().Height.Ceil() * 5 / 4
+	y := cardMargin + r.title.Metrics().Ascent.Ceil()
+	for _, line := range wrapText(d, title, cardWidth-2*cardMargin, cardLines) {
+		d.Dot = fixed.P(cardMargin, y)
+		d.DrawString(line)
+		y += lineHeight
+	}
+
+	d.Face = r.site
+	d.Dot = fixed.P(cardMargin, cardHeight-cardMargin)
+	d.DrawString(site)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// wrapText splits text into at most n lines fitting in width,
+// ending the last one with an ellipsis if the text doesn't fit.
+func wrapText(d *font.Drawer, text string, width, n int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		next := strings.TrimSpace(line + " " + word)
+		if line != "" && d.MeasureString(next).Ceil() > width {
+			lines = append(lines, line)
+			next = word
+		}
+		line = next
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	if len(lines) > n {
+		lines = lines[:n]
+		last := lines[n-1] + "…"
+		for d.MeasureString(last).Ceil() > width && strings.Contains(last, " ") {
+			last = last[:strings.LastIndex(last, " ")] + "…"
+		}
+		lines[n-1] = last
+	}
+	return lines
+}
+
+// parseColor parses a color in the #rgb or #rrggbb notation.
+func parseColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return nil, fmt.Errorf("invalid color: %s", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, nil
+}
@@ -0,0 +1,49 @@
+package site
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// applyCascades copies the values of the cascade front matter map
+// of every _index.md to the pages under its directory, the _index.md
+// itself excluded. Values set by the page or by the cascade of a
+// closer _index.md take precedence.
+func applyCascades(pages Pages) {
+	cascades := make(map[string]map[string]interface{})
+	for _, page := range pages {
+		if cascade, ok := page.Meta["cascade"].(map[string]interface{}); ok && page.isSectionList() {
+			cascades[filepath.Dir(page.RelPath)] = cascade
+		}
+	}
+	if len(cascades) == 0 {
+		return
+	}
+	// closer directories first
+	dirs := make([]string, 0, len(cascades))
+	for dir := range cascades {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+
+	for i := range pages {
+		page := &pages[i]
+		for _, dir := range dirs {
+			if page.isSectionList() && filepath.Dir(page.RelPath) == dir {
+				continue
+			}
+			if dir != "." && !isWithin(page.RelPath, dir) {
+				continue
+			}
+			if page.Meta == nil {
+				page.Meta = make(map[string]interface{})
+			}
+			for key, value := range cascades[dir] {
+				if _, ok := page.Meta[key]; !ok {
+					page.Meta[key] = value
+				}
+			}
+		}
+		page.Tags = metaList(page.Meta["tags"])
+	}
+}
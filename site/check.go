@@ -0,0 +1,201 @@
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// linksCacheFile keeps the results of the external link checks
+// in the output directory.
+const linksCacheFile = ".marc-links.json"
+
+// linkStatus is the result of checking an external link.
+type linkStatus struct {
+	Status  int       `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Checked time.Time `json:"checked"`
+}
+
+func (s linkStatus) ok() bool {
+	return s.Error == "" && s.Status < 400
+}
+
+func (s linkStatus) String() string {
+	if s.Error != "" {
+		return s.Error
+	}
+	return fmt.Sprintf("%d %s", s.Status, http.StatusText(s.Status))
+}
+
+// CheckOptions are the options of Check.
+type CheckOptions struct {
+	// External has the links to other sites checked too.
+	External bool
+	// Concurrency is the number of sites checked at once,
+	// 8 if not set.
+	Concurrency int
+	// Delay is the delay between requests to the same site.
+	Delay time.Duration
+	// MaxAge is the age after which working links are checked again.
+	MaxAge time.Duration
+}
+
+// Check checks the links of the site as last built,
+// failing if any is broken.
+func (s *Site) Check(opts CheckOptions) (err error) {
+	defer recoverError(&err)
+	outDir, cfg := s.outDir, s.cfg
+	broken, err := s.checkLinks(outDir, cfg, nil, nil)
+	if err != nil {
+		fatalIO("failed to check links:", err)
+	}
+	for _, link := range broken {
+		s.logWarning("%s", link)
+	}
+	failed := len(broken)
+
+	if opts.External {
+		links, err := externalLinks(outDir, cfg)
+		if err != nil {
+			fatalIO("failed to check links:", err)
+		}
+		if opts.Concurrency <= 0 {
+			opts.Concurrency = 8
+		}
+		cache := readLinksCache(outDir)
+		checkExternal(links, cache, opts.Concurrency, opts.Delay, opts.MaxAge)
+		writeLinksCache(outDir, cache)
+		for _, link := range links {
+			if status := cache[link.URL]; !status.ok() {
+				s.logWarning("%s (%s)", link, status)
+				failed++
+			}
+		}
+	}
+	if failed > 0 {
+		fatalf("%d broken links", failed)
+	}
+	return nil
+}
+
+// externalLinks returns the links to other sites
+// of the html files in the output directory.
+func externalLinks(outDir string, cfg Config) ([]brokenLink, error) {
+	var links []brokenLink
+	err := filepath.WalkDir(outDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".html" {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, link := range htmlLinks(content) {
+			u, err := url.Parse(link.URL)
+			if err != nil || !isExternal(u, cfg) || u.Scheme != "http" && u.Scheme != "https" {
+				continue
+			}
+			link.File = path
+			links = append(links, link)
+		}
+		return nil
+	})
+	return links, err
+}
+
+// checkExternal checks the links not in the cache or checked
+// longer than maxAge ago, updating the cache. The sites are checked
+// concurrently, each one link at a time with a delay between them.
+func checkExternal(links []brokenLink, cache map[string]linkStatus, concurrency int, delay, maxAge time.Duration) {
+	hosts := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, link := range links {
+		status, ok := cache[link.URL]
+		if seen[link.URL] || ok && status.ok() && time.Since(status.Checked) < maxAge {
+			continue
+		}
+		seen[link.URL] = true
+		u, _ := url.Parse(link.URL)
+		hosts[u.Host] = append(hosts[u.Host], link.URL)
+	}
+	names := make([]string, 0, len(hosts))
+	for host := range hosts {
+		names = append(names, host)
+	}
+	sort.Strings(names)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	var mu sync.Mutex
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range jobs {
+				for i, link := range hosts[host] {
+					if i > 0 {
+						time.Sleep(delay)
+					}
+					status := checkURL(client, link)
+					mu.Lock()
+					cache[link] = status
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, host := range names {
+		jobs <- host
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// checkURL requests the headers of a url, falling back to a GET
+// for servers that don't allow HEAD requests.
+func checkURL(client *http.Client, link string) linkStatus {
+	status := linkStatus{Checked: time.Now()}
+	resp, err := client.Head(link)
+	if err == nil && resp.StatusCode >= 400 {
+		resp.Body.Close()
+		resp, err = client.Get(link)
+	}
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	resp.Body.Close()
+	status.Status = resp.StatusCode
+	return status
+}
+
+func readLinksCache(outDir string) map[string]linkStatus {
+	cache := make(map[string]linkStatus)
+	data, err := os.ReadFile(filepath.Join(outDir, linksCacheFile))
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return make(map[string]linkStatus)
+	}
+	return cache
+}
+
+func writeLinksCache(outDir string, cache map[string]linkStatus) {
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		fatal("failed to encode link cache:", err)
+	}
+	if err := os.WriteFile(filepath.Join(outDir, linksCacheFile), data, 0600); err != nil {
+		fatalIO("failed to write link cache:", err)
+	}
+}
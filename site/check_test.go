@@ -0,0 +1,49 @@
+package site
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		html string
+		opts CheckOptions
+		err  bool
+	}{
+		{name: "internal", html: `<a href="/index.html">home</a>`},
+		{name: "broken internal", html: `<a href="/missing.html">missing</a>`, err: true},
+		{name: "external not checked", html: `<a href="` + server.URL + `/missing">x</a>`},
+		{name: "external", html: `<a href="` + server.URL + `/page">x</a>`, opts: CheckOptions{External: true}},
+		{name: "broken external", html: `<a href="` + server.URL + `/missing">x</a>`, opts: CheckOptions{External: true}, err: true},
+	}
+	for _, test := range tests {
+		outDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(outDir, "index.html"), []byte(test.html), 0644); err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan error)
+		s := New(t.TempDir(), outDir, Config{BaseURL: "https://example.com/"})
+		go func() { done <- s.Check(test.opts) }()
+		select {
+		case err := <-done:
+			if (err != nil) != test.err {
+				t.Errorf("%s: got error %v, want error %v", test.name, err, test.err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: Check did not return", test.name)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package site
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The site generators whose conventions the compat config maps
+// onto marc's, to build their sites without rewriting them.
+const (
+	Jekyll = "jekyll"
+	Hugo   = "hugo"
+)
+
+// jekyllPost matches the file names of Jekyll posts,
+// 2006-01-02-title.md, capturing the date and the name.
+var jekyllPost = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+
+// compatIncludes returns the patterns of the underscore-prefixed
+// files kept in the site for compat.
+func compatIncludes(cfg Config) []string {
+	if cfg.Compat == Jekyll {
+		return []string{"/_posts/", "/_drafts/"}
+	}
+	return nil
+}
+
+// compatIgnores returns the patterns of the files of the other
+// generator left out of the site for compat.
+func compatIgnores(cfg Config) []string {
+	if cfg.Compat == Jekyll {
+		return []string{"/Gemfile", "/Gemfile.lock", "/vendor/"}
+	}
+	return nil
+}
+
+// compatRoots returns the directories Hugo reads the pages and
+// static files from, content/ and static/, if the site has them.
+func compatRoots(siteDir string, cfg Config) []contentRoot {
+	if cfg.Compat != Hugo {
+		return nil
+	}
+	var roots []contentRoot
+	for _, name := range []string{"content", "static"} {
+		dir := filepath.Join(siteDir, name)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			roots = append(roots, contentRoot{dir: dir})
+		}
+	}
+	return roots
+}
+
+// applyCompat maps the conventions of the compat generator onto
+// the page: Jekyll posts in _posts/2006-01-02-title.md are put in
+// posts/title.md, dated from their name, and those of _drafts/ are
+// drafts; categories are added to the tags; and an excerpt field or
+// excerpt_separator ends the summary, as marc's summary and
+// <!--more--> do.
+func applyCompat(page *Page, cfg Config) {
+	if cfg.Compat == "" {
+		return
+	}
+	if page.Meta == nil {
+		page.Meta = make(map[string]interface{})
+	}
+	if cfg.Compat == Jekyll {
+		parts := strings.SplitN(filepath.ToSlash(page.RelPath), "/", 2)
+		if len(parts) == 2 && (parts[0] == "_posts" || parts[0] == "_drafts") {
+			name := filepath.Base(parts[1])
+			if m := jekyllPost.FindStringSubmatch(name); m != nil {
+				name = m[2]
+				if _, ok := page.Meta["date"]; !ok {
+					page.Meta["date"] = m[1]
+				}
+			}
+			if parts[0] == "_drafts" {
+				page.Meta["draft"] = true
+			}
+			page.RelPath = filepath.Join("posts", filepath.Dir(filepath.FromSlash(parts[1])), name)
+			page.Section = "posts"
+		}
+	}
+
+	tags := metaList(page.Meta["tags"])
+	for _, key := range []string{"categories", "category"} {
+		for _, category := range metaList(page.Meta[key]) {
+			if !contains(tags, category) {
+				tags = append(tags, category)
+			}
+		}
+	}
+	if len(tags) > 0 {
+		list := make([]interface{}, len(tags))
+		for i, tag := range tags {
+			list[i] = tag
+		}
+		page.Meta["tags"] = list
+		page.Tags = tags
+	}
+
+	if excerpt := page.metaString("excerpt"); excerpt != "" && page.metaString("summary") == "" {
+		page.Meta["summary"] = excerpt
+	}
+	if sep := page.metaString("excerpt_separator"); sep != "" && !bytes.Equal([]byte(sep), moreMarker) {
+		page.Text = bytes.Replace(page.Text, []byte(sep), append(append([]byte("\n\n"), moreMarker...), "\n\n"...), 1)
+	}
+}
+
+// compatURL returns the url set by the page for compat: Jekyll's
+// permalink or Hugo's url field, a pattern as in the permalinks config.
+func compatURL(page Page, cfg Config) (string, bool, error) {
+	key := ""
+	switch cfg.Compat {
+	case Jekyll:
+		key = "permalink"
+	case Hugo:
+		key = "url"
+	}
+	pattern := page.metaString(key)
+	if key == "" || pattern == "" {
+		return "", false, nil
+	}
+	url, err := permalink(pattern, page, cfg)
+	return url, true, err
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
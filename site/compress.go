@@ -0,0 +1,75 @@
+package site
+
+import (
+	"bytes"
+	"compress/gzip"
+	"path/filepath"
+
+	"github.com/andybalholm/brotli"
+)
+
+// CompressConfig controls the precompressed copies of the output
+// files, for servers that can serve them as is.
+type CompressConfig struct {
+	Gzip        bool     `toml:"gzip" yaml:"gzip"`
+	Brotli      bool     `toml:"brotli" yaml:"brotli"`
+	GzipLevel   int      `toml:"gzipLevel" yaml:"gzipLevel"`
+	BrotliLevel int      `toml:"brotliLevel" yaml:"brotliLevel"`
+	Types       []string `toml:"types" yaml:"types"`
+}
+
+// canCompress reports whether compressed copies of the file are written.
+func (s *Site) canCompress(path string) bool {
+	if !s.cfg.Compress.Gzip && !s.cfg.Compress.Brotli {
+		return false
+	}
+	for _, ext := range s.cfg.Compress.Types {
+		if filepath.Ext(path) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// keepOutput records the file at path, left as it is, and the
+// compressed copies of it the current build would write as outputs.
+func (s *Site) keepOutput(path string) {
+	s.outputs.add(path)
+	if !s.canCompress(path) {
+		return
+	}
+	if s.cfg.Compress.Gzip {
+		s.outputs.add(path + ".gz")
+	}
+	if s.cfg.Compress.Brotli {
+		s.outputs.add(path + ".br")
+	}
+}
+
+// writeCompressed writes the .gz and .br copies of the file.
+func (s *Site) writeCompressed(path string, body []byte) {
+	if !s.canCompress(path) {
+		return
+	}
+	var buf bytes.Buffer
+	if s.cfg.Compress.Gzip {
+		w, err := gzip.NewWriterLevel(&buf, s.cfg.Compress.GzipLevel)
+		if err != nil {
+			fatal("failed to compress file:", err)
+		}
+		w.Write(body)
+		w.Close()
+		if err := s.writeOutput(path+".gz", buf.Bytes()); err != nil {
+			fatalIO("failed to write file:", err)
+		}
+	}
+	if s.cfg.Compress.Brotli {
+		buf.Reset()
+		w := brotli.NewWriterLevel(&buf, s.cfg.Compress.BrotliLevel)
+		w.Write(body)
+		w.Close()
+		if err := s.writeOutput(path+".br", buf.Bytes()); err != nil {
+			fatalIO("failed to write file:", err)
+		}
+	}
+}
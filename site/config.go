@@ -0,0 +1,307 @@
+package site
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/andybalholm/brotli"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the site-wide settings. It is read from marc.toml
+// or marc.yaml in the site root and exposed to templates as .Site.
+type Config struct {
+	Title             string                 `toml:"title" yaml:"title"`
+	BaseURL           string                 `toml:"baseURL" yaml:"baseURL"`
+	Author            string                 `toml:"author" yaml:"author"`
+	Authors           map[string]*Author     `toml:"authors" yaml:"authors"`
+	AuthorPages       bool                   `toml:"authorPages" yaml:"authorPages"`
+	SeriesPages       bool                   `toml:"seriesPages" yaml:"seriesPages"`
+	Output            string                 `toml:"output" yaml:"output"`
+	Theme             string                 `toml:"theme" yaml:"theme"`
+	Drafts            bool                   `toml:"drafts" yaml:"drafts"`
+	Future            bool                   `toml:"future" yaml:"future"`
+	Expired           bool                   `toml:"expired" yaml:"expired"`
+	Required          []string               `toml:"required" yaml:"required"`
+	Schema            map[string]FieldSchema `toml:"schema" yaml:"schema"`
+	Paginate          int                    `toml:"paginate" yaml:"paginate"`
+	Related           int                    `toml:"related" yaml:"related"`
+	SummaryParagraphs int                    `toml:"summaryParagraphs" yaml:"summaryParagraphs"`
+	DateFormats       map[string]string      `toml:"dateFormats" yaml:"dateFormats"`
+	Timezone          string                 `toml:"timezone" yaml:"timezone"`
+	Permalinks        map[string]string      `toml:"permalinks" yaml:"permalinks"`
+	Sort              SortConfig             `toml:"sort" yaml:"sort"`
+	Slugify           bool                   `toml:"slugify" yaml:"slugify"`
+	UglyURLs          bool                   `toml:"uglyURLs" yaml:"uglyURLs"`
+	DefaultLanguage   string                 `toml:"defaultLanguage" yaml:"defaultLanguage"`
+	Languages         map[string]Language    `toml:"languages" yaml:"languages"`
+	Menus             map[string]Menu        `toml:"menus" yaml:"menus"`
+	Markdown          MarkdownConfig         `toml:"markdown" yaml:"markdown"`
+	Renderers         map[string]string      `toml:"renderers" yaml:"renderers"`
+	ContentTypes      map[string]string      `toml:"contentTypes" yaml:"contentTypes"`
+	Feeds             []string               `toml:"feeds" yaml:"feeds"`
+	FeedScope         FeedScopeConfig        `toml:"feedScope" yaml:"feedScope"`
+	SectionFeeds      map[string][]string    `toml:"sectionFeeds" yaml:"sectionFeeds"`
+	Minify            bool                   `toml:"minify" yaml:"minify"`
+	Compress          CompressConfig         `toml:"compress" yaml:"compress"`
+	Images            ImagesConfig           `toml:"images" yaml:"images"`
+	Search            SearchConfig           `toml:"search" yaml:"search"`
+	PageFormats       []string               `toml:"pageFormats" yaml:"pageFormats"`
+	Sitemap           SitemapConfig          `toml:"sitemap" yaml:"sitemap"`
+	Archive           ArchiveConfig          `toml:"archive" yaml:"archive"`
+	Robots            bool                   `toml:"robots" yaml:"robots"`
+	CNAME             string                 `toml:"cname" yaml:"cname"`
+	NoJekyll          bool                   `toml:"nojekyll" yaml:"nojekyll"`
+	NetlifyRedirects  bool                   `toml:"netlifyRedirects" yaml:"netlifyRedirects"`
+	Platform          string                 `toml:"platform" yaml:"platform"`
+	Redirects         []Redirect             `toml:"redirects" yaml:"redirects"`
+	Headers           []HeaderRule           `toml:"headers" yaml:"headers"`
+	SocialCards       CardConfig             `toml:"socialCards" yaml:"socialCards"`
+	IndieWeb          IndieWebConfig         `toml:"indieweb" yaml:"indieweb"`
+	GitInfo           bool                   `toml:"gitInfo" yaml:"gitInfo"`
+	Hooks             HooksConfig            `toml:"hooks" yaml:"hooks"`
+	Deploy            []DeployTarget         `toml:"deploy" yaml:"deploy"`
+	Ignore            []string               `toml:"ignore" yaml:"ignore"`
+	Include           []string               `toml:"include" yaml:"include"`
+	ContentDirs       []string               `toml:"contentDirs" yaml:"contentDirs"`
+	Remote            []RemoteSource         `toml:"remote" yaml:"remote"`
+	FollowSymlinks    bool                   `toml:"followSymlinks" yaml:"followSymlinks"`
+	LowMemory         bool                   `toml:"lowMemory" yaml:"lowMemory"`
+	// Compat is the generator, jekyll or hugo, whose
+	// conventions the site follows.
+	Compat string `toml:"compat" yaml:"compat"`
+
+	// Force disables incremental builds, set with -force.
+	Force bool `toml:"-" yaml:"-" json:"-"`
+	// Strict fails the build on invalid front matter, set with -strict.
+	Strict bool `toml:"-" yaml:"-" json:"-"`
+	// Stats is the file the build statistics are written to,
+	// set with -stats.
+	Stats string `toml:"-" yaml:"-" json:"-"`
+	// Clean removes the files of the output the build
+	// no longer writes, set with -clean.
+	Clean bool `toml:"-" yaml:"-" json:"-"`
+	// DryRun builds the site without writing anything,
+	// set with -dry-run.
+	DryRun bool `toml:"-" yaml:"-" json:"-"`
+	// Dev is set when serving the site, embedding source maps
+	// in the compiled stylesheets.
+	Dev bool `toml:"-" yaml:"-" json:"-"`
+	// FailOnBrokenLinks fails the build on broken internal links,
+	// set with -fail-on-broken-links.
+	FailOnBrokenLinks bool `toml:"-" yaml:"-" json:"-"`
+	// Offline builds with the copies of the remote sources
+	// fetched last, set with -offline.
+	Offline bool `toml:"-" yaml:"-" json:"-"`
+	// Env is the environment the site is built for, production
+	// or development, set with -env or MARC_ENV.
+	Env string `toml:"-" yaml:"-" json:"-"`
+}
+
+type MarkdownConfig struct {
+	Unsafe        bool           `toml:"unsafe" yaml:"unsafe"`
+	AutoHeadingID bool           `toml:"autoHeadingID" yaml:"autoHeadingID"`
+	Extensions    []string       `toml:"extensions" yaml:"extensions"`
+	Math          bool           `toml:"math" yaml:"math"`
+	Wikilinks     WikilinkConfig `toml:"wikilinks" yaml:"wikilinks"`
+	// HardWraps renders the newlines of paragraphs as <br>.
+	HardWraps bool `toml:"hardWraps" yaml:"hardWraps"`
+	// XHTML renders void elements as <br />.
+	XHTML bool `toml:"xhtml" yaml:"xhtml"`
+	// Attributes enables the {#id .class key=value} attribute
+	// syntax after headings.
+	Attributes bool `toml:"attributes" yaml:"attributes"`
+	// HeadingIDPrefix is put before the ids of the headings,
+	// to keep them from clashing with the template's.
+	HeadingIDPrefix string `toml:"headingIDPrefix" yaml:"headingIDPrefix"`
+	// Mermaid renders ```mermaid code blocks as diagrams.
+	Mermaid bool `toml:"mermaid" yaml:"mermaid"`
+	// Emoji renders :shortcodes: as unicode emoji.
+	Emoji bool `toml:"emoji" yaml:"emoji"`
+	// Typographer turns straight quotes, -- and ... into
+	// typographic quotes, dashes and ellipses.
+	Typographer bool `toml:"typographer" yaml:"typographer"`
+	// Figures renders images alone in a paragraph with a title
+	// as figures captioned by the title.
+	Figures bool `toml:"figures" yaml:"figures"`
+	// LazyImages has the images loaded lazily.
+	LazyImages bool `toml:"lazyImages" yaml:"lazyImages"`
+	// HeadingAnchor is the text of the link to itself added
+	// to each heading with an id, none if empty.
+	HeadingAnchor string `toml:"headingAnchor" yaml:"headingAnchor"`
+	// ExternalLinks sets the attributes of the links to other sites.
+	ExternalLinks ExternalLinkConfig `toml:"externalLinks" yaml:"externalLinks"`
+}
+
+// readConfigFile reads the first of the config files
+// found in siteDir into cfg, keeping the values it doesn't set.
+func (s *Site) readConfigFile(siteDir string, names []string, cfg *Config) {
+	for _, name := range names {
+		path := filepath.Join(siteDir, name)
+		text, err := s.readSource(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			fatalIO("failed to read config: ", err)
+		}
+		if filepath.Ext(name) == ".toml" {
+			err = toml.Unmarshal(text, cfg)
+		} else {
+			err = yaml.Unmarshal(text, cfg)
+		}
+		if err != nil {
+			fatalf("failed to parse %s: %s", name, err)
+		}
+		return
+	}
+}
+
+// absURL turns a site-relative url into an absolute one.
+func (c Config) absURL(url string) string {
+	return strings.TrimSuffix(c.BaseURL, "/") + "/" + strings.TrimPrefix(url, "/")
+}
+
+var configFiles = []string{"marc.toml", "marc.yaml", "marc.yml"}
+
+// The environments marc builds sites for by default:
+// production, or development when serving them.
+const (
+	Production  = "production"
+	Development = "development"
+)
+
+// envConfigFiles returns the names of the config files
+// of an environment, marc.production.toml and so on.
+func envConfigFiles(env string) []string {
+	names := make([]string, len(configFiles))
+	for i, name := range configFiles {
+		ext := filepath.Ext(name)
+		names[i] = strings.TrimSuffix(name, ext) + "." + env + ext
+	}
+	return names
+}
+
+func defaultConfig() Config {
+	return Config{
+		Output:            "public",
+		Related:           5,
+		SummaryParagraphs: 1,
+		UglyURLs:          true,
+		Feeds:             []string{"atom"},
+		FeedScope:         FeedScopeConfig{Site: true, Sections: true},
+		Sort:              SortConfig{By: "date"},
+		ContentTypes: map[string]string{
+			".md": Markdown,
+		},
+		Renderers: map[string]string{
+			".adoc":     asciidoctor,
+			".asciidoc": asciidoctor,
+			".org":      pandocOrg,
+		},
+		Compress: CompressConfig{
+			GzipLevel:   gzip.BestCompression,
+			BrotliLevel: brotli.BestCompression,
+			Types:       []string{".html", ".css", ".js", ".json", ".xml", ".svg", ".txt"},
+		},
+		Search: SearchConfig{
+			Fields: []string{"title", "url", "tags", "summary"},
+		},
+		Markdown: MarkdownConfig{
+			Unsafe:        true,
+			AutoHeadingID: true,
+			Extensions:    []string{"table", "strikethrough", "tasklist", "linkify", "footnote"},
+			ExternalLinks: ExternalLinkConfig{
+				Target: "_blank",
+				Rel:    "noopener",
+			},
+		},
+	}
+}
+
+// isConfigFile reports whether name is a config file,
+// of the site or of an environment.
+func isConfigFile(name string) bool {
+	ext := filepath.Ext(name)
+	for _, configFile := range configFiles {
+		if name == configFile {
+			return true
+		}
+		if filepath.Ext(configFile) == ext && strings.HasPrefix(name, "marc.") && strings.Count(name, ".") == 2 {
+			return true
+		}
+	}
+	return false
+}
+
+// readConfig reads the config of the site, overridden
+// by the config of the environment if there is one.
+func (s *Site) readConfig(siteDir, env string) Config {
+	cfg := defaultConfig()
+	s.readConfigFile(siteDir, configFiles, &cfg)
+	if env == "" {
+		env = Production
+	}
+	s.readConfigFile(siteDir, envConfigFiles(env), &cfg)
+	cfg.Env = env
+	if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+		fatalf("unknown timezone %q", cfg.Timezone)
+	}
+	if _, ok := cfg.Languages[cfg.DefaultLanguage]; len(cfg.Languages) > 0 && !ok {
+		fatalf("default language %q is not one of the languages", cfg.DefaultLanguage)
+	}
+	if cfg.Sort.Order != "" && cfg.Sort.Order != "asc" && cfg.Sort.Order != "desc" {
+		fatalf("unknown sort order %q, not asc or desc", cfg.Sort.Order)
+	}
+	switch cfg.Markdown.Wikilinks.Unresolved {
+	case "", "text", "warn", "error":
+	default:
+		fatalf("unknown wikilinks.unresolved %q, not text, warn or error", cfg.Markdown.Wikilinks.Unresolved)
+	}
+	if cfg.Platform != "" && cfg.Platform != Netlify && cfg.Platform != Vercel {
+		fatalf("unknown platform %q, not netlify or vercel", cfg.Platform)
+	}
+	if cfg.Compat != "" && cfg.Compat != Jekyll && cfg.Compat != Hugo {
+		fatalf("unknown compat %q, not jekyll or hugo", cfg.Compat)
+	}
+	feeds := append([]string{}, cfg.Feeds...)
+	for _, section := range cfg.SectionFeeds {
+		feeds = append(feeds, section...)
+	}
+	for _, name := range feeds {
+		if _, ok := feedFormats[name]; !ok {
+			fatalf("unknown feed format %q, not atom, json or rss", name)
+		}
+	}
+	for _, format := range cfg.PageFormats {
+		if !pageFormats[format] {
+			fatalf("unknown page format %q, not json or yaml", format)
+		}
+	}
+	for ext, t := range cfg.ContentTypes {
+		if !strings.HasPrefix(ext, ".") || t != Markdown && !strings.HasPrefix(t, ".") {
+			fatalf("unknown content type %q of %q, not markdown or the extension of a renderer", t, ext)
+		}
+	}
+	for name, menu := range cfg.Menus {
+		cfg.Menus[name] = menu.tree()
+	}
+	for id, author := range cfg.Authors {
+		if author == nil {
+			author = &Author{}
+			cfg.Authors[id] = author
+		}
+		author.ID = id
+		if author.Name == "" {
+			author.Name = id
+		}
+		if cfg.AuthorPages {
+			author.Url = authorURL(id)
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,93 @@
+package site
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+)
+
+// Convert renders the document text, at relpath in the site,
+// with the site's templates and writes the HTML to w, as building
+// the site would write it. Links to other pages, images and wiki
+// links are left as they are.
+func (s *Site) Convert(relpath string, text []byte, w io.Writer) (err error) {
+	defer recoverError(&err)
+	s.convert(s.dir, relpath, text, s.cfg, w)
+	return nil
+}
+
+func (s *Site) convert(siteDir, relpath string, source []byte, cfg Config, w io.Writer) {
+	meta, body, err := readMeta(source, s.dates)
+	if err != nil {
+		fatalf("failed to parse front matter: %s", err)
+	}
+	r, isRendered := s.pageRenderer(filepath.Ext(relpath))
+	if isRendered && meta == nil && r.Meta != nil {
+		if meta, body, err = r.Meta(body); err != nil {
+			fatalf("failed to read metadata: %s", err)
+		}
+	}
+	page := Page{
+		Meta:    meta,
+		Tags:    metaList(meta["tags"]),
+		AbsPath: filepath.Join(siteDir, relpath),
+		RelPath: relpath,
+		Text:    body,
+		dates:   s.dates,
+	}
+	page.Lang = pageLang(relpath, cfg)
+	if _, ok := meta["date"]; ok {
+		if page.Date, err = page.date(); err != nil {
+			fatal(err)
+		}
+	}
+	if page.Url, err = pageURL(page, cfg); err != nil {
+		fatalf("failed to build url: %s", err)
+	}
+
+	theme := s.themeDir(siteDir, cfg)
+	tmplDirs := []string{siteDir}
+	if theme != "" {
+		tmplDirs = append(tmplDirs, theme)
+	}
+	partials := s.readPartials(tmplDirs)
+	tmpl := s.readTmpl(tmplDirs, partials, "base.tmpl", defaultTmpl)
+	if name := page.layout(); name != "" {
+		if tmpl = s.readTmpl(tmplDirs, partials, name, nil); tmpl == nil {
+			fatalf("layout %s not found", name)
+		}
+	} else if name := s.sectionLayout(siteDir, relpath); name != "" {
+		tmpl = s.readTmpl([]string{siteDir}, partials, name, nil)
+	}
+	data, _, err := s.readData(tmplDirs)
+	if err != nil {
+		fatalf("failed to read data: %s", err)
+	}
+
+	shortcodes, err := s.readShortcodes(tmplDirs, partials)
+	if err != nil {
+		fatalf("failed to parse shortcodes: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := s.convertPage(s.newMarkdown(cfg.Markdown), &buf, &page, shortcodes, cfg, nil); err != nil {
+		fatal(err)
+	}
+
+	buf.Reset()
+	out, err := s.execute(&buf, tmpl, map[string]interface{}{
+		"Page":  page,
+		"Pages": Pages{page},
+		"Tags":  map[string]Pages{},
+		"Site":  cfg,
+		"Data":  data,
+	})
+	if err == nil {
+		out, err = filterHTML(&page, out)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if _, err := w.Write(out); err != nil {
+		fatalIO("failed to write page:", err)
+	}
+}
@@ -0,0 +1,116 @@
+package site
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// dataDir holds the data files, available in templates as .Data,
+// as does jekyllDataDir, the files of dataDir winning.
+const (
+	dataDir       = "data"
+	jekyllDataDir = "_data"
+)
+
+// dataRoots returns the data directories of dir,
+// in increasing order of precedence.
+func dataRoots(dir string) []string {
+	return []string{filepath.Join(dir, jekyllDataDir), filepath.Join(dir, dataDir)}
+}
+
+// readData reads the YAML, JSON, TOML and CSV files in the data
+// directories of the given dirs into nested maps keyed by directory
+// and file name, so data/authors/jane.yaml is .Data.authors.jane.
+// The dirs are in order of precedence. The paths of the files read
+// are returned as well.
+func (s *Site) readData(dirs []string) (map[string]interface{}, []string, error) {
+	data := make(map[string]interface{})
+	var files []string
+	var roots []string
+	for i := len(dirs) - 1; i >= 0; i-- {
+		roots = append(roots, dataRoots(dirs[i])...)
+	}
+	for _, root := range roots {
+		err := s.walkSource(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == root {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if isHidden(d.Name()) && path != root {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			value, err := s.readDataFile(path)
+			if err != nil {
+				return fmt.Errorf("%s: %s", path, err)
+			}
+			if value == nil {
+				return nil
+			}
+			relpath, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			keys := strings.Split(filepath.ToSlash(strings.TrimSuffix(relpath, filepath.Ext(relpath))), "/")
+			m := data
+			for _, key := range keys[:len(keys)-1] {
+				next, ok := m[key].(map[string]interface{})
+				if !ok {
+					next = make(map[string]interface{})
+					m[key] = next
+				}
+				m = next
+			}
+			m[keys[len(keys)-1]] = value
+			files = append(files, path)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return data, files, nil
+}
+
+// readDataFile decodes a data file by its extension,
+// returning nil for files of other types.
+func (s *Site) readDataFile(path string) (interface{}, error) {
+	var value interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json", ".toml", ".csv":
+	default:
+		return nil, nil
+	}
+	text, err := s.readSource(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(text, &value)
+	case ".json":
+		err = json.Unmarshal(text, &value)
+	case ".toml":
+		var m map[string]interface{}
+		err = toml.Unmarshal(text, &m)
+		value = m
+	case ".csv":
+		value, err = csv.NewReader(strings.NewReader(string(text))).ReadAll()
+	}
+	return value, err
+}
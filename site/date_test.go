@@ -0,0 +1,43 @@
+package site
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDateLocation(t *testing.T) {
+	zone := time.FixedZone("EST", -5*60*60)
+	dates := &dateConfig{location: zone, formats: dateFormats}
+
+	tests := []struct {
+		front string
+		want  time.Time
+	}{
+		{front: "---\ndate: 2024-03-01T10:00:00\n---\n", want: time.Date(2024, 3, 1, 10, 0, 0, 0, zone)},
+		{front: "---\ndate: 2024-03-01 10:00\n---\n", want: time.Date(2024, 3, 1, 10, 0, 0, 0, zone)},
+		{front: "---\ndate: 2024-03-01T10:00:00Z\n---\n", want: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{front: "---\ndate: 2024-03-01T10:00:00+02:00\n---\n", want: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)},
+		{front: "---\ndate: \"2024-03-01 10:00:00 +0100\"\n---\n", want: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)},
+		{front: "+++\ndate = 2024-03-01T10:00:00\n+++\n", want: time.Date(2024, 3, 1, 10, 0, 0, 0, zone)},
+		{front: "+++\ndate = 2024-03-01\n+++\n", want: time.Date(2024, 3, 1, 0, 0, 0, 0, zone)},
+		{front: "+++\ndate = 2024-03-01T10:00:00Z\n+++\n", want: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		meta, _, err := readMeta([]byte(test.front), dates)
+		if err != nil {
+			t.Errorf("%q: %v", test.front, err)
+			continue
+		}
+		got, err := Page{Meta: meta, dates: dates}.date()
+		if err != nil {
+			t.Errorf("%q: %v", test.front, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("%q: got %v, want %v", test.front, got, test.want)
+		}
+		if got.Location() != zone {
+			t.Errorf("%q: shown in %v, want %v", test.front, got.Location(), zone)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// writeDeployFiles writes the small files static hosts look for:
+// robots.txt, CNAME for GitHub Pages custom domains and the
+// .nojekyll marker. Files of the same name in the site win.
+func (s *Site) writeDeployFiles(outDir string, cfg Config, assets map[string]string) {
+	if cfg.Robots && assets["robots.txt"] == "" {
+		var b strings.Builder
+		b.WriteString("User-agent: *\nAllow: /\n")
+		if cfg.BaseURL != "" {
+			fmt.Fprintf(&b, "\nSitemap: %s\n", cfg.absURL("sitemap.xml"))
+		}
+		s.writeFile(filepath.Join(outDir, "robots.txt"), []byte(b.String()))
+	}
+	if cfg.CNAME != "" && assets["CNAME"] == "" {
+		s.writeFile(filepath.Join(outDir, "CNAME"), []byte(cfg.CNAME+"\n"))
+	}
+	if cfg.NoJekyll {
+		s.writeFile(filepath.Join(outDir, ".nojekyll"), nil)
+	}
+}
+
+// DeployTarget is where marc deploy uploads the built site.
+type DeployTarget struct {
+	// Name selects the target with -target, the first
+	// being deployed to by default.
+	Name string `toml:"name" yaml:"name"`
+	// Type is rsync, s3 or gh-pages.
+	Type string `toml:"type" yaml:"type"`
+	// Dest is the rsync destination, such as user@host:/var/www/site.
+	Dest string `toml:"dest" yaml:"dest"`
+	// Bucket, Region, Endpoint and Prefix locate the files of an
+	// S3 bucket, the endpoint of AWS in the region by default.
+	Bucket   string `toml:"bucket" yaml:"bucket"`
+	Region   string `toml:"region" yaml:"region"`
+	Endpoint string `toml:"endpoint" yaml:"endpoint"`
+	Prefix   string `toml:"prefix" yaml:"prefix"`
+	// CacheControl sets the Cache-Control header of the files
+	// uploaded to S3, the first pattern matching winning.
+	CacheControl []CacheControlRule `toml:"cacheControl" yaml:"cacheControl"`
+	// Remote and Branch are the repository and branch the site is
+	// pushed to with gh-pages, by default the origin of the site's
+	// repository and gh-pages.
+	Remote string `toml:"remote" yaml:"remote"`
+	Branch string `toml:"branch" yaml:"branch"`
+	// Delete removes the files of the target missing from the output.
+	Delete bool `toml:"delete" yaml:"delete"`
+}
+
+// CacheControlRule sets the Cache-Control header of the files
+// matching a pattern, as in the ignore config.
+type CacheControlRule struct {
+	Pattern string `toml:"pattern" yaml:"pattern"`
+	Value   string `toml:"value" yaml:"value"`
+}
+
+// deploy uploads outDir to the target of the config named name,
+// or the first one if empty, only logging what it would do if dryRun.
+func (s *Site) deploy(siteDir, outDir string, cfg Config, name string, dryRun bool) {
+	if len(cfg.Deploy) == 0 {
+		fatal("no deploy targets in the config")
+	}
+	target := cfg.Deploy[0]
+	if name != "" {
+		found := false
+		for _, t := range cfg.Deploy {
+			if t.Name == name {
+				target, found = t, true
+				break
+			}
+		}
+		if !found {
+			fatalf("no deploy target named %q", name)
+		}
+	}
+	if info, err := os.Stat(outDir); err != nil || !info.IsDir() {
+		fatalf("%s is not built", outDir)
+	}
+	switch target.Type {
+	case "rsync":
+		s.deployRsync(outDir, target, dryRun)
+	case "s3":
+		s.deployS3(outDir, target, dryRun)
+	case "gh-pages":
+		s.deployGitHubPages(siteDir, outDir, target, dryRun)
+	default:
+		fatalf("unknown deploy type %q, not rsync, s3 or gh-pages", target.Type)
+	}
+}
+
+// isDeployed reports whether the output file at relpath is
+// deployed, unlike marc's own caches.
+func isDeployed(relpath string) bool {
+	name := filepath.Base(relpath)
+	return name != cacheFile && name != linksCacheFile
+}
+
+// deployFiles returns the slash-separated paths of the files
+// of outDir to deploy, sorted.
+func deployFiles(outDir string) []string {
+	var files []string
+	err := filepath.WalkDir(outDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		relpath, err := filepath.Rel(outDir, path)
+		if err != nil {
+			return err
+		}
+		if isDeployed(relpath) {
+			files = append(files, filepath.ToSlash(relpath))
+		}
+		return nil
+	})
+	if err != nil {
+		fatalIO("failed to read output:", err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// deployRsync syncs outDir to the destination with rsync.
+func (s *Site) deployRsync(outDir string, target DeployTarget, dryRun bool) {
+	if target.Dest == "" {
+		fatal("rsync deploy target without dest")
+	}
+	args := []string{"--archive", "--compress", "--itemize-changes",
+		"--exclude", cacheFile, "--exclude", linksCacheFile}
+	if target.Delete {
+		args = append(args, "--delete")
+	}
+	if dryRun {
+		args = append(args, "--dry-run")
+	}
+	args = append(args, strings.TrimSuffix(outDir, string(filepath.Separator))+string(filepath.Separator), target.Dest)
+	s.runDeployCommand("", "rsync", args...)
+}
+
+// deployGitHubPages commits outDir as the only commit of a branch,
+// force-pushed to the remote.
+func (s *Site) deployGitHubPages(siteDir, outDir string, target DeployTarget, dryRun bool) {
+	remote, branch := target.Remote, target.Branch
+	if remote == "" {
+		out, err := exec.Command("git", "-C", siteDir, "remote", "get-url", "origin").Output()
+		if err != nil {
+			fatal("gh-pages deploy target without remote, and the site has no origin")
+		}
+		remote = strings.TrimSpace(string(out))
+	}
+	if branch == "" {
+		branch = "gh-pages"
+	}
+	if dryRun {
+		for _, file := range deployFiles(outDir) {
+			s.logf("+ %s", file)
+		}
+		s.logf("would push to %s %s", remote, branch)
+		return
+	}
+	gitDir, err := os.MkdirTemp("", "marc-deploy")
+	if err != nil {
+		fatalIO("failed to deploy:", err)
+	}
+	defer os.RemoveAll(gitDir)
+	gitArgs := func(args ...string) []string {
+		return append([]string{"--git-dir", gitDir, "--work-tree", outDir,
+			"-c", "user.name=marc", "-c", "user.email=marc@localhost"}, args...)
+	}
+	s.runDeployCommand("", "git", gitArgs("init", "--quiet")...)
+	s.runDeployCommand("", "git", gitArgs("add", "--all", "--", ".", ":!"+cacheFile, ":!"+linksCacheFile)...)
+	s.runDeployCommand("", "git", gitArgs("commit", "--quiet", "--message", "Deploy the site")...)
+	s.runDeployCommand("", "git", gitArgs("push", "--force", remote, "HEAD:refs/heads/"+branch)...)
+}
+
+// runDeployCommand runs a command in dir, logging its output.
+func (s *Site) runDeployCommand(dir, name string, args ...string) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	out = bytes.TrimSpace(out)
+	if err != nil {
+		fatalf("%s failed: %s\n%s", name, err, out)
+	}
+	if len(out) > 0 && verbosity >= Normal {
+		for _, line := range strings.Split(string(out), "\n") {
+			s.logf("  %s", line)
+		}
+	}
+}
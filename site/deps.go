@@ -0,0 +1,188 @@
+package site
+
+import (
+	"html/template"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// tmplDeps finds the template, partial and data files a page
+// template uses, so that changing one of them only rebuilds
+// the pages rendered with it.
+type tmplDeps struct {
+	site *Site
+	dirs []string
+	// partials maps the names of the partials to their files,
+	// the site's overriding the theme's.
+	partials map[string]string
+	// data maps the top-level keys of .Data to their files.
+	data   map[string][]string
+	hashes map[*template.Template]string
+}
+
+func (s *Site) newTmplDeps(dirs []string, partials []tmplFile, dataFiles []string) *tmplDeps {
+	d := &tmplDeps{
+		site:     s,
+		dirs:     dirs,
+		partials: make(map[string]string),
+		data:     make(map[string][]string),
+		hashes:   make(map[*template.Template]string),
+	}
+	for _, partial := range partials {
+		d.partials[partial.name] = partial.path
+	}
+	for _, path := range dataFiles {
+		if key := dataKey(dirs, path); key != "" {
+			d.data[key] = append(d.data[key], path)
+		}
+	}
+	return d
+}
+
+// dataKey returns the key of .Data holding the data file at path,
+// authors for data/authors.yaml and data/authors/jane.yaml.
+func dataKey(dirs []string, path string) string {
+	for _, dir := range dirs {
+		for _, root := range dataRoots(dir) {
+			relpath, err := filepath.Rel(root, path)
+			if err != nil || strings.HasPrefix(relpath, "..") {
+				continue
+			}
+			key := strings.Split(filepath.ToSlash(relpath), "/")[0]
+			return strings.TrimSuffix(key, filepath.Ext(key))
+		}
+	}
+	return ""
+}
+
+// hash returns the hash of the files the template uses.
+// It is not safe for concurrent use.
+func (d *tmplDeps) hash(tmpl *template.Template) string {
+	if tmpl == nil {
+		return ""
+	}
+	if hash, ok := d.hashes[tmpl]; ok {
+		return hash
+	}
+	var chunks [][]byte
+	for _, path := range d.files(tmpl) {
+		text, err := d.site.readSource(path)
+		if err != nil {
+			fatalIO("failed to read ", err)
+		}
+		chunks = append(chunks, []byte(path), text)
+	}
+	d.hashes[tmpl] = hashBytes(chunks...)
+	return d.hashes[tmpl]
+}
+
+// files returns the file of the template, those of the partials
+// it calls, directly or not, and those of the data it reads,
+// sorted. A template using .Data other than by key, such as
+// {{ range .Data }}, uses all the data files.
+func (d *tmplDeps) files(tmpl *template.Template) []string {
+	files := make(map[string]bool)
+	if path := d.site.tmplPath(d.dirs, tmpl.Name()); path != "" {
+		files[path] = true
+	}
+	keys := make(map[string]bool)
+	useData := func(ident []string) {
+		if len(ident) == 0 || ident[0] != "Data" {
+			return
+		}
+		if len(ident) == 1 {
+			keys["*"] = true
+		} else {
+			keys[ident[1]] = true
+		}
+	}
+	seen := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		t := tmpl.Lookup(name)
+		if seen[name] || t == nil || t.Tree == nil {
+			return
+		}
+		seen[name] = true
+		if path := d.partials[name]; path != "" && name != tmpl.Name() {
+			files[path] = true
+		}
+		walkTree(t.Tree.Root, func(node parse.Node) {
+			switch node := node.(type) {
+			case *parse.TemplateNode:
+				visit(node.Name)
+			case *parse.FieldNode:
+				useData(node.Ident)
+			case *parse.VariableNode:
+				if len(node.Ident) > 1 && node.Ident[0] == "$" {
+					useData(node.Ident[1:])
+				}
+			}
+		})
+	}
+	visit(tmpl.Name())
+	for key := range keys {
+		for k, paths := range d.data {
+			if key == "*" || key == k {
+				for _, path := range paths {
+					files[path] = true
+				}
+			}
+		}
+	}
+	list := make([]string, 0, len(files))
+	for path := range files {
+		list = append(list, path)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// walkTree calls visit for the node and each node under it.
+func walkTree(node parse.Node, visit func(parse.Node)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkTree(child, visit)
+		}
+		return
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walkTree(cmd, visit)
+		}
+		return
+	}
+	visit(node)
+	switch n := node.(type) {
+	case *parse.ActionNode:
+		walkTree(n.Pipe, visit)
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkTree(arg, visit)
+		}
+	case *parse.ChainNode:
+		walkTree(n.Node, visit)
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, visit)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, visit)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, visit)
+	case *parse.TemplateNode:
+		walkTree(n.Pipe, visit)
+	}
+}
+
+func walkBranch(n *parse.BranchNode, visit func(parse.Node)) {
+	walkTree(n.Pipe, visit)
+	walkTree(n.List, visit)
+	walkTree(n.ElseList, visit)
+}
@@ -0,0 +1,48 @@
+package site
+
+import (
+	"html/template"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTmplDeps(t *testing.T) {
+	s := newSite(fstest.MapFS{
+		"page.html":             {Data: []byte("page")},
+		"partials/header.html":  {Data: []byte("header")},
+		"theme/list.html":       {Data: []byte("list")},
+		"theme/partials/a.html": {Data: []byte("a")},
+	}, "site", false, nil, "", Config{})
+	dirs := []string{"site", "site/theme"}
+	partials := []tmplFile{
+		{name: "header", path: "site/partials/header.html"},
+		{name: "footer", path: "site/theme/partials/a.html"},
+		{name: "builtin"},
+	}
+	dataFiles := []string{"site/data/authors.yaml", "site/data/menu/main.yaml", "site/theme/data/links.toml"}
+	partialsText := `{{ define "header" }}{{ .Data.authors }}{{ template "builtin" . }}{{ end }}` +
+		`{{ define "footer" }}{{ range $.Data.links }}{{ end }}{{ end }}` +
+		`{{ define "builtin" }}{{ end }}`
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "page.html", text: `{{ .Page.HTML }}`, want: []string{"site/page.html"}},
+		{name: "missing.html", text: `{{ .Page.HTML }}`, want: []string{}},
+		{name: "list.html", text: `{{ template "header" . }}`, want: []string{"site/data/authors.yaml", "site/partials/header.html", "site/theme/list.html"}},
+		{name: "page.html", text: `{{ if .Page }}{{ template "footer" . }}{{ end }}`, want: []string{"site/page.html", "site/theme/data/links.toml", "site/theme/partials/a.html"}},
+		{name: "page.html", text: `{{ with .Data.menu }}{{ .main }}{{ end }}`, want: []string{"site/data/menu/main.yaml", "site/page.html"}},
+		{name: "page.html", text: `{{ range $k, $v := .Data }}{{ end }}`, want: []string{"site/data/authors.yaml", "site/data/menu/main.yaml", "site/page.html", "site/theme/data/links.toml"}},
+	}
+	for _, test := range tests {
+		tmpl := template.Must(template.New(test.name).Parse(test.text))
+		template.Must(tmpl.Parse(partialsText))
+		deps := s.newTmplDeps(dirs, partials, dataFiles)
+		if got := deps.files(tmpl); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s %s: got %q, want %q", test.name, test.text, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package site
+
+import (
+	"bytes"
+	"os"
+)
+
+// wouldWrite logs whether writing body to the file at path
+// would create or change it, if either.
+func (s *Site) wouldWrite(path string, body []byte) {
+	s.keepOutput(path)
+	old, err := s.readOutput(path)
+	switch {
+	case os.IsNotExist(err):
+		s.logChange("create", path)
+	case err != nil || !bytes.Equal(old, body):
+		s.logChange("change", path)
+	}
+}
+
+// wouldMake logs whether a file made by an external command
+// would be created or changed.
+func (s *Site) wouldMake(path string) {
+	s.outputs.add(path)
+	if _, err := s.statOutput(path); os.IsNotExist(err) {
+		s.logChange("create", path)
+	} else {
+		s.logChange("change", path)
+	}
+}
+
+// logChange logs a change of the output in a dry run,
+// as "+ path" when created, "~ path" when changed or
+// "- path" when removed.
+func (s *Site) logChange(change, path string) {
+	if jsonLogs {
+		s.writeEvent(logEvent{Level: "info", Event: change, Path: path})
+		return
+	}
+	sign := "+"
+	switch change {
+	case "change":
+		sign = "~"
+	case "remove":
+		sign = "-"
+	}
+	s.logf("%s %s", sign, path)
+}
@@ -0,0 +1,71 @@
+package site
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// pageErrors collects the errors of the pages failing to build,
+// letting the rest of the site build before they're reported.
+type pageErrors struct {
+	mu   sync.Mutex
+	errs map[string]error
+}
+
+func (e *pageErrors) add(relpath string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.errs == nil {
+		e.errs = make(map[string]error)
+	}
+	// the first error of a page is the one worth reporting
+	if _, ok := e.errs[relpath]; !ok {
+		e.errs[relpath] = err
+	}
+}
+
+func (e *pageErrors) failed(relpath string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.errs[relpath]
+	return ok
+}
+
+// err returns an error summing up the errors of the pages by path,
+// or nil if none failed.
+func (e *pageErrors) err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.errs) == 0 {
+		return nil
+	}
+	lines := make([]string, 0, len(e.errs))
+	for relpath, err := range e.errs {
+		lines = append(lines, fmt.Sprintf("%s: %s", relpath, err))
+	}
+	sort.Strings(lines)
+	return fmt.Errorf("%d pages failed to build:\n  %s", len(lines), strings.Join(lines, "\n  "))
+}
+
+// report logs the errors of the pages, as an event per page in the
+// JSON logs of s, and exits if any failed.
+func (e *pageErrors) report(s *Site) {
+	err := e.err()
+	if err == nil {
+		return
+	}
+	if !jsonLogs {
+		fatal(err)
+	}
+	relpaths := make([]string, 0, len(e.errs))
+	for relpath := range e.errs {
+		relpaths = append(relpaths, relpath)
+	}
+	sort.Strings(relpaths)
+	for _, relpath := range relpaths {
+		s.writeEvent(logEvent{Level: "error", Event: "page", Path: relpath, Message: e.errs[relpath].Error()})
+	}
+	fatalf("%d pages failed to build", len(relpaths))
+}
@@ -0,0 +1,435 @@
+package site
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExportOptions are the options of Export.
+type ExportOptions struct {
+	// Format is epub, or pdf, rendered from a single HTML
+	// document by PDFCommand.
+	Format string
+	// Section is the directory of the pages exported,
+	// the whole site if empty.
+	Section string
+	// PDFCommand converts the HTML on stdin to the PDF file
+	// named by its last argument, weasyprint by default.
+	PDFCommand string
+}
+
+// exportCSS styles the exported pages, printed pages breaking
+// before each of them.
+const exportCSS = `body { font-family: serif; line-height: 1.5; }
+img { max-width: 100%; }
+pre { white-space: pre-wrap; }
+section.page { break-before: page; }
+`
+
+// Export writes the pages of a section of the site, in the order
+// of the sort config, to a single book-like file at out.
+func (s *Site) Export(out string, opts ExportOptions) (err error) {
+	defer recoverError(&err)
+	s.exportBook(s.dir, out, s.cfg, opts)
+	return nil
+}
+
+// exportPage is a page of an export, its HTML rendered.
+type exportPage struct {
+	Page
+	// Path is the path of the page in the book.
+	Path string
+}
+
+func (s *Site) exportBook(siteDir, out string, cfg Config, opts ExportOptions) {
+	section := filepath.Clean(filepath.FromSlash(strings.Trim(opts.Section, "/")))
+	if opts.Format == "" || opts.Format == "epub" {
+		// EPUB pages are XHTML
+		cfg.Markdown.XHTML = true
+	}
+	title, pages, files := s.readExportPages(siteDir, section, cfg)
+	if len(pages) == 0 {
+		fatalf("no pages to export in %q", opts.Section)
+	}
+	switch opts.Format {
+	case "", "epub":
+		s.writeEPUB(out, title, cfg, pages, files)
+	case "pdf":
+		s.writePDF(siteDir, section, out, title, pages, opts.PDFCommand)
+	default:
+		fatalf("unknown export format %q, not epub or pdf", opts.Format)
+	}
+}
+
+// readExportPages reads the published pages under the section of the
+// content directories, sorted, with their HTML, and the static files
+// under it by path in the book. The title of the book is that of the
+// section's _index.md, or of the site.
+func (s *Site) readExportPages(siteDir, section string, cfg Config) (string, []exportPage, map[string]string) {
+	md := s.newMarkdown(cfg.Markdown)
+	ignored := s.readIgnoreRules(siteDir, cfg)
+	var pages Pages
+	files := make(map[string]string)
+	seen := make(map[string]bool)
+	roots := s.contentRoots(siteDir, cfg)
+	for _, root := range roots {
+		err := s.walkSource(root.dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && p == root.dir {
+					return nil
+				}
+				return err
+			}
+			relpath, err := filepath.Rel(root.dir, p)
+			if err != nil || relpath == "." {
+				return err
+			}
+			relpath = filepath.Join(root.mount, relpath)
+			if ignored.match(relpath, d.IsDir()) || s.isSkipped(d.Name()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if isContentRoot(p, roots) || s.isIgnoredDir(p, siteDir, "") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if section != "." && !isWithin(relpath, section) || seen[relpath] {
+				return nil
+			}
+			seen[relpath] = true
+			if !s.isPage(p) {
+				if isStatic(p) {
+					files[filepath.ToSlash(relpath)] = p
+				}
+				return nil
+			}
+			page, err := s.readPage(p, relpath)
+			if err != nil {
+				return err
+			}
+			applyCompat(&page, cfg)
+			if page.Meta["draft"] == true && !cfg.Drafts {
+				return nil
+			}
+			if _, ok := page.Meta["date"]; ok {
+				if page.Date, err = page.date(); err != nil {
+					return fmt.Errorf("%s: %s", relpath, err)
+				}
+			}
+			pages = append(pages, page)
+			return nil
+		})
+		if err != nil {
+			fatal("failed to read site:", err)
+		}
+	}
+	title, order := cfg.Title, cfg.Sort
+	for _, page := range pages {
+		if page.isSectionList() && filepath.Dir(page.RelPath) == section {
+			if t := page.metaString("title"); t != "" {
+				title = t
+			}
+			if s, ok := page.sortConfig(); ok {
+				order = s
+			}
+		}
+	}
+	sortPages(pages, order)
+
+	var exported []exportPage
+	var buf bytes.Buffer
+	for _, page := range pages {
+		if page.isSectionList() && filepath.Dir(page.RelPath) == section && len(bytes.TrimSpace(page.Text)) == 0 {
+			continue
+		}
+		if r, ok := s.pageRenderer(filepath.Ext(page.AbsPath)); ok {
+			if err := s.renderPage(r, &page, 0); err != nil {
+				fatalf("%s: %s", page.RelPath, err)
+			}
+		} else {
+			buf.Reset()
+			if err := md.Convert(page.Text, &buf); err != nil {
+				fatalf("%s: failed to convert markdown: %s", page.RelPath, err)
+			}
+			page.HTML = template.HTML(buf.String())
+		}
+		name := strings.TrimSuffix(filepath.ToSlash(page.RelPath), filepath.Ext(page.RelPath)) + ".xhtml"
+		exported = append(exported, exportPage{Page: page, Path: name})
+	}
+	return title, exported, files
+}
+
+// title returns the title of an exported page,
+// its file name if it has none.
+func (p exportPage) title() string {
+	if title := p.metaString("title"); title != "" {
+		return title
+	}
+	return titleFromName(p.filename())
+}
+
+// xmlHeader starts the XHTML documents of an EPUB, written before
+// the templates as html/template escapes it.
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8"?>
+`
+
+var epubPageTmpl = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="{{ .Lang }}">
+<head>
+<meta charset="UTF-8" />
+<title>{{ .Title }}</title>
+<link rel="stylesheet" href="{{ .CSS }}" />
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+{{ .HTML }}
+</body>
+</html>
+`))
+
+var epubNavTmpl = template.Must(template.New("nav").Parse(`<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="{{ .Lang }}">
+<head>
+<meta charset="UTF-8" />
+<title>{{ .Title }}</title>
+</head>
+<body>
+<nav epub:type="toc">
+<h1>{{ .Title }}</h1>
+<ol>
+{{- range .Pages }}
+<li><a href="{{ .Path }}">{{ .Title }}</a></li>
+{{- end }}
+</ol>
+</nav>
+</body>
+</html>
+`))
+
+// writeEPUB writes the pages and the files they use
+// to an EPUB 3 book at out.
+func (s *Site) writeEPUB(out, title string, cfg Config, pages []exportPage, files map[string]string) {
+	lang := cfg.DefaultLanguage
+	if lang == "" {
+		lang = "en"
+	}
+	modified := time.Now().UTC()
+	if epoch, ok := sourceDate(); ok {
+		modified = epoch
+	}
+
+	var book bytes.Buffer
+	zw := zip.NewWriter(&book)
+	add := func(name string, data []byte, method uint16) {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: modified})
+		if err == nil {
+			_, err = w.Write(data)
+		}
+		if err != nil {
+			fatalIO("failed to write book:", err)
+		}
+	}
+	// the mimetype comes first, uncompressed
+	add("mimetype", []byte("application/epub+zip"), zip.Store)
+	add("META-INF/container.xml", []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles>
+<rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+</rootfiles>
+</container>
+`), zip.Deflate)
+	add("OEBPS/book.css", []byte(exportCSS), zip.Deflate)
+
+	type navPage struct{ Path, Title string }
+	var nav []navPage
+	var manifest, spine strings.Builder
+	var buf bytes.Buffer
+	for i, page := range pages {
+		buf.Reset()
+		buf.WriteString(xmlHeader)
+		err := epubPageTmpl.Execute(&buf, map[string]interface{}{
+			"Lang":  lang,
+			"Title": page.title(),
+			"CSS":   relativePath(page.Path, "book.css"),
+			"HTML":  page.HTML,
+		})
+		if err != nil {
+			fatal("failed to render page:", err)
+		}
+		add("OEBPS/"+page.Path, buf.Bytes(), zip.Deflate)
+		id := fmt.Sprintf("page%d", i+1)
+		fmt.Fprintf(&manifest, "<item id=\"%s\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", id, xmlEscape(page.Path))
+		fmt.Fprintf(&spine, "<itemref idref=\"%s\"/>\n", id)
+		nav = append(nav, navPage{Path: page.Path, Title: page.title()})
+	}
+	i := 0
+	for _, name := range fileNames(files) {
+		mediaType := mediaTypeOf(name)
+		if mediaType == "" {
+			continue
+		}
+		data, err := s.readSource(files[name])
+		if err != nil {
+			fatalIO("failed to read file:", err)
+		}
+		add("OEBPS/"+name, data, zip.Deflate)
+		i++
+		fmt.Fprintf(&manifest, "<item id=\"file%d\" href=\"%s\" media-type=\"%s\"/>\n", i, xmlEscape(name), mediaType)
+	}
+	buf.Reset()
+	buf.WriteString(xmlHeader)
+	if err := epubNavTmpl.Execute(&buf, map[string]interface{}{"Lang": lang, "Title": title, "Pages": nav}); err != nil {
+		fatal("failed to render contents:", err)
+	}
+	add("OEBPS/nav.xhtml", buf.Bytes(), zip.Deflate)
+
+	id := "urn:marc:" + hashBytes([]byte(cfg.BaseURL), []byte(title))[:32]
+	opf := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id" xml:lang="%s">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="id">%s</dc:identifier>
+<dc:title>%s</dc:title>
+<dc:creator>%s</dc:creator>
+<dc:language>%s</dc:language>
+<meta property="dcterms:modified">%s</meta>
+</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+<item id="css" href="book.css" media-type="text/css"/>
+%s</manifest>
+<spine>
+%s</spine>
+</package>
+`, xmlEscape(lang), id, xmlEscape(title), xmlEscape(cfg.Author), xmlEscape(lang),
+		modified.Format("2006-01-02T15:04:05Z"), manifest.String(), spine.String())
+	add("OEBPS/content.opf", []byte(opf), zip.Deflate)
+	if err := zw.Close(); err != nil {
+		fatalIO("failed to write book:", err)
+	}
+	if err := os.WriteFile(out, book.Bytes(), 0644); err != nil {
+		fatalIO("failed to write book:", err)
+	}
+	s.logOutput(out)
+}
+
+var pdfTmpl = template.Must(template.New("pdf").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8">
+<title>{{ .Title }}</title>
+<style>{{ .CSS }}</style>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+{{- range .Pages }}
+<section class="page">
+<h1>{{ .Title }}</h1>
+{{ .HTML }}
+</section>
+{{- end }}
+</body>
+</html>
+`))
+
+// writePDF renders the pages to a single HTML document, printed
+// to a PDF at out by the command, relative links resolving
+// in the section's directory.
+func (s *Site) writePDF(siteDir, section, out, title string, pages []exportPage, command string) {
+	type pdfPage struct {
+		Title string
+		HTML  template.HTML
+	}
+	list := make([]pdfPage, len(pages))
+	for i, page := range pages {
+		list[i] = pdfPage{Title: page.title(), HTML: page.HTML}
+	}
+	var buf bytes.Buffer
+	err := pdfTmpl.Execute(&buf, map[string]interface{}{
+		"Title": title,
+		"CSS":   template.CSS(exportCSS),
+		"Pages": list,
+	})
+	if err != nil {
+		fatal("failed to render book:", err)
+	}
+	dir, err := s.diskPath(filepath.Join(siteDir, section))
+	if err != nil {
+		fatalf("pdf export: %s", err)
+	}
+	if command == "" {
+		command = "weasyprint --base-url . -"
+	}
+	cmd := shellCommand(command + " " + shellQuote(absPath(out)))
+	cmd.Dir = dir
+	cmd.Stdin = &buf
+	if output, err := cmd.CombinedOutput(); err != nil {
+		fatalf("%q failed: %s\n%s", command, err, bytes.TrimSpace(output))
+	}
+	s.logOutput(out)
+}
+
+// shellQuote quotes s as a single argument of a shell command.
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// relativePath returns the slash-separated path of target,
+// relative to the directory of the file at from.
+func relativePath(from, target string) string {
+	depth := strings.Count(from, "/")
+	return strings.Repeat("../", depth) + target
+}
+
+// mediaTypeOf returns the media type of the files an EPUB can
+// hold, empty for the others.
+func mediaTypeOf(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".png":
+		return "image/png"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".gif":
+		return "image/gif"
+	case ".svg":
+		return "image/svg+xml"
+	case ".webp":
+		return "image/webp"
+	case ".css":
+		return "text/css"
+	}
+	return ""
+}
+
+// fileNames returns the paths of the files of an export, sorted.
+func fileNames(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package site
+
+import (
+	"net/url"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// ExternalLinkConfig sets the attributes of the links to other sites.
+type ExternalLinkConfig struct {
+	Enabled bool   `toml:"enabled" yaml:"enabled"`
+	Target  string `toml:"target" yaml:"target"`
+	Rel     string `toml:"rel" yaml:"rel"`
+	Class   string `toml:"class" yaml:"class"`
+}
+
+// externalLinkTransformer adds the configured attributes to the http
+// and https links pointing outside of the site's baseURL.
+type externalLinkTransformer struct {
+	cfg     ExternalLinkConfig
+	baseURL string
+}
+
+func (t *externalLinkTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	site := Config{BaseURL: t.baseURL}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		var dest []byte
+		switch n := n.(type) {
+		case *ast.Link:
+			dest = n.Destination
+		case *ast.AutoLink:
+			if n.AutoLinkType != ast.AutoLinkURL {
+				return ast.WalkContinue, nil
+			}
+			dest = n.URL(reader.Source())
+		default:
+			return ast.WalkContinue, nil
+		}
+		u, err := url.Parse(string(dest))
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" || !isExternal(u, site) {
+			return ast.WalkContinue, nil
+		}
+		if t.cfg.Target != "" {
+			n.SetAttributeString("target", []byte(t.cfg.Target))
+		}
+		if t.cfg.Rel != "" {
+			n.SetAttributeString("rel", []byte(t.cfg.Rel))
+		}
+		if t.cfg.Class != "" {
+			n.SetAttributeString("class", []byte(t.cfg.Class))
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+type externalLinkExtension struct {
+	cfg     ExternalLinkConfig
+	baseURL string
+}
+
+func (e *externalLinkExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(util.Prioritized(&externalLinkTransformer{cfg: e.cfg, baseURL: e.baseURL}, 500)),
+	)
+}
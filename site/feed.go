@@ -0,0 +1,251 @@
+package site
+
+import (
+	"encoding/xml"
+	"html/template"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const feedLimit = 20
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title     string       `xml:"title"`
+	ID        string       `xml:"id"`
+	Link      atomLink     `xml:"link"`
+	Published string       `xml:"published,omitempty"`
+	Updated   string       `xml:"updated"`
+	Authors   []atomAuthor `xml:"author"`
+	Summary   *atomText    `xml:"summary,omitempty"`
+	Content   *atomText    `xml:"content,omitempty"`
+}
+
+type feedFormat struct {
+	file  string
+	mime  string
+	write func(s *Site, outDir string, cfg Config, feed feedInfo, pages Pages)
+}
+
+// feedFormats are the feeds that can be enabled in the config.
+var feedFormats = map[string]feedFormat{
+	"atom": {"feed.xml", "application/atom+xml", (*Site).writeAtomFeed},
+	"json": {"feed.json", "application/feed+json", (*Site).writeJSONFeed},
+	"rss":  {"rss.xml", "application/rss+xml", (*Site).writeRSSFeed},
+}
+
+// FeedScopeConfig chooses the pages feeds are written for,
+// besides the tags: the whole site, each top-level section,
+// or both.
+type FeedScopeConfig struct {
+	Site     bool `toml:"site" yaml:"site"`
+	Sections bool `toml:"sections" yaml:"sections"`
+}
+
+// feedInfo describes one of the site's feeds: the site-wide one
+// or the feed of a section or tag, written to its directory.
+type feedInfo struct {
+	dir   string
+	title string
+	file  string
+}
+
+// FeedLink is a feed of the site, for autodiscovery links.
+type FeedLink struct {
+	URL   string
+	Type  string
+	Title string
+}
+
+// FeedLinks returns the absolute urls of the configured feeds in the
+// directory, "" for the site feeds, e.g. "posts/" or "tags/go/".
+// It returns none for the feeds the feedScope config leaves out.
+func (c Config) FeedLinks(dir string) []FeedLink {
+	if c.BaseURL == "" {
+		return nil
+	}
+	var links []FeedLink
+	for _, name := range c.feedsOf(dir) {
+		if format, ok := feedFormats[name]; ok {
+			links = append(links, FeedLink{
+				URL:   c.absURL(dir + format.file),
+				Type:  format.mime,
+				Title: c.Title,
+			})
+		}
+	}
+	return links
+}
+
+// feedsOf returns the formats of the feeds written to dir: those
+// the sectionFeeds config lists for a section, or else the feeds
+// config, unless feedScope leaves out the site or the sections.
+func (c Config) feedsOf(dir string) []string {
+	switch {
+	case dir == "":
+		if !c.FeedScope.Site {
+			return nil
+		}
+	case strings.HasPrefix(dir, "tags/"):
+	default:
+		if feeds, ok := c.SectionFeeds[strings.TrimSuffix(dir, "/")]; ok {
+			return feeds
+		}
+		if !c.FeedScope.Sections {
+			return nil
+		}
+	}
+	return c.Feeds
+}
+
+// writeFeeds writes the configured feeds of the latest dated pages
+// of the site and of each top-level section, as the feedScope and
+// sectionFeeds config pick, and of each tag.
+// Feeds require absolute links, so nothing is written without baseURL.
+// If reconvert is not nil, as in low-memory builds, it's called to
+// convert the text of the pages in the feeds again, for their content.
+func (s *Site) writeFeeds(outDir string, cfg Config, pages Pages, tags map[string]Pages, reconvert func(page *Page) error) {
+	if cfg.BaseURL == "" {
+		s.logWarning("skipping feeds: baseURL is not set")
+		return
+	}
+
+	converted := make(map[string]template.HTML)
+	full := func(page Page) Page {
+		if html, ok := converted[page.RelPath]; ok {
+			page.HTML = html
+			return page
+		}
+		// pages failing to convert, already reported,
+		// keep their summary
+		if err := reconvert(&page); err != nil {
+			return page
+		}
+		converted[page.RelPath] = page.HTML
+		return page
+	}
+
+	write := func(dir, title string, pages Pages) {
+		feeds := cfg.feedsOf(dir)
+		if len(feeds) == 0 {
+			return
+		}
+		latest := make(Pages, 0, feedLimit)
+		for _, page := range pages {
+			if len(latest) == feedLimit {
+				break
+			}
+			if _, err := page.date(); err == nil {
+				if reconvert != nil {
+					page = full(page)
+				}
+				latest = append(latest, page)
+			}
+		}
+		for _, name := range feeds {
+			format := feedFormats[name]
+			format.write(s, outDir, cfg, feedInfo{dir: dir, title: title, file: format.file}, latest)
+		}
+	}
+
+	write("", cfg.Title, pages)
+	sections := make(map[string]Pages)
+	for _, page := range pages {
+		if page.Section != "" {
+			sections[page.Section] = append(sections[page.Section], page)
+		}
+	}
+	for _, section := range sortedKeys(sections) {
+		write(filepath.ToSlash(section)+"/", cfg.Title+": "+section, sections[section])
+	}
+	for _, tag := range sortedKeys(tags) {
+		write("tags/"+slugify(tag)+"/", cfg.Title+": "+tag, tags[tag])
+	}
+}
+
+func sortedKeys(m map[string]Pages) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// feedTitle returns the title of a page for feeds.
+func (p Page) feedTitle() string {
+	if title := p.metaString("title"); title != "" {
+		return title
+	}
+	return p.RelPath
+}
+
+// writeAtomFeed writes an Atom feed of the pages.
+func (s *Site) writeAtomFeed(outDir string, cfg Config, info feedInfo, pages Pages) {
+	feed := atomFeed{
+		Title: info.title,
+		ID:    cfg.absURL(info.dir),
+		Links: []atomLink{
+			{Href: cfg.absURL(info.dir)},
+			{Href: cfg.absURL(info.dir + info.file), Rel: "self"},
+		},
+	}
+	if cfg.Author != "" {
+		feed.Author = &atomAuthor{Name: cfg.Author}
+	}
+
+	var updated time.Time
+	for _, page := range pages {
+		date, _ := page.date()
+		if page.updated().After(updated) {
+			updated = page.updated()
+		}
+		entry := atomEntry{
+			Title:     page.feedTitle(),
+			ID:        cfg.absURL(page.Url),
+			Link:      atomLink{Href: cfg.absURL(page.Url)},
+			Published: date.Format(time.RFC3339),
+			Updated:   page.updated().Format(time.RFC3339),
+			Content:   &atomText{Type: "html", Body: string(page.content())},
+		}
+		for _, author := range page.Authors {
+			entry.Authors = append(entry.Authors, atomAuthor{Name: author.Name})
+		}
+		if page.Summary != "" {
+			entry.Summary = &atomText{Type: "html", Body: string(page.Summary)}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	feed.Updated = updated.Format(time.RFC3339)
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		fatal("failed to render feed:", err)
+	}
+	s.writeFile(filepath.Join(outDir, filepath.FromSlash(info.dir), info.file), append([]byte(xml.Header), body...))
+}
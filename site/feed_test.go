@@ -0,0 +1,56 @@
+package site
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteFeedsReconvert(t *testing.T) {
+	outDir := t.TempDir()
+	cfg := defaultConfig()
+	cfg.BaseURL = "https://example.com/"
+	cfg.Feeds = []string{"atom"}
+	s := New(t.TempDir(), outDir, cfg)
+
+	var pages Pages
+	for _, name := range []string{"a", "b"} {
+		date := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+		pages = append(pages, Page{
+			RelPath: name + ".md",
+			Url:     name + ".html",
+			Tags:    []string{"go"},
+			Date:    date,
+			Meta:    map[string]interface{}{"title": name, "date": date},
+			Summary: template.HTML("summary of " + name),
+		})
+	}
+	tags := map[string]Pages{"go": pages}
+	converted := make(map[string]int)
+	reconvert := func(page *Page) error {
+		converted[page.RelPath]++
+		page.HTML = template.HTML("full text of " + page.RelPath)
+		return nil
+	}
+	s.writeFeeds(outDir, cfg, pages, tags, reconvert)
+
+	for _, path := range []string{"feed.xml", "tags/go/feed.xml"} {
+		body, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, page := range pages {
+			if !strings.Contains(string(body), "full text of "+page.RelPath) {
+				t.Errorf("%s: no full text of %s", path, page.RelPath)
+			}
+		}
+	}
+	for _, page := range pages {
+		if converted[page.RelPath] != 1 {
+			t.Errorf("%s converted %d times, want once", page.RelPath, converted[page.RelPath])
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package site
+
+import (
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// The figures extension renders the images standing alone in a
+// paragraph with a title as figures, captioned by the title, and
+// can have images lazy-loaded.
+
+var kindFigure = ast.NewNodeKind("Figure")
+
+type figureNode struct {
+	ast.BaseBlock
+	Caption []byte
+}
+
+func (n *figureNode) Kind() ast.NodeKind { return kindFigure }
+
+func (n *figureNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Caption": string(n.Caption)}, nil)
+}
+
+type figureTransformer struct {
+	figures bool
+	lazy    bool
+}
+
+func (t *figureTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	var images []*ast.Image
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if img, ok := n.(*ast.Image); ok && entering {
+			images = append(images, img)
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, img := range images {
+		if t.lazy {
+			img.SetAttributeString("loading", []byte("lazy"))
+		}
+		para, ok := img.Parent().(*ast.Paragraph)
+		if !t.figures || len(img.Title) == 0 || !ok || para.ChildCount() != 1 {
+			continue
+		}
+		figure := &figureNode{Caption: img.Title}
+		img.Title = nil
+		para.Parent().ReplaceChild(para.Parent(), para, figure)
+		figure.AppendChild(figure, img)
+	}
+}
+
+type figureRenderer struct{}
+
+func (r *figureRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindFigure, r.renderFigure)
+}
+
+func (r *figureRenderer) renderFigure(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		w.WriteString("<figure>")
+		return ast.WalkContinue, nil
+	}
+	w.WriteString("<figcaption>")
+	w.Write(util.EscapeHTML(node.(*figureNode).Caption))
+	w.WriteString("</figcaption></figure>\n")
+	return ast.WalkContinue, nil
+}
+
+type figureExtension struct {
+	figures bool
+	lazy    bool
+}
+
+func (e *figureExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(util.Prioritized(&figureTransformer{figures: e.figures, lazy: e.lazy}, 500)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&figureRenderer{}, 500)),
+	)
+}
@@ -0,0 +1,79 @@
+package site
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// fingerprinter is used by the fingerprint template function,
+// set up by build.
+type fingerprinter struct {
+	mu     sync.Mutex
+	site   *Site
+	outDir string
+	assets map[string]string
+	urls   map[string]string
+	// names maps the files to their fingerprinted names.
+	names map[string]string
+}
+
+func (f *fingerprinter) reset(site *Site, outDir string, assets map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.site = site
+	f.outDir = outDir
+	f.assets = assets
+	f.urls = make(map[string]string)
+	f.names = make(map[string]string)
+}
+
+// fingerprint copies a static file of the site, given by its path
+// from the site root, to a name including a hash of its content
+// (css/site.css to css/site.ab12cd34.css) and returns its url.
+func (s *Site) fingerprint(name string) (string, error) {
+	f := &s.fingerprints
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	if url, ok := f.urls[name]; ok {
+		return url, nil
+	}
+	src, ok := f.assets[filepath.FromSlash(name)]
+	if !ok {
+		return "", fmt.Errorf("fingerprint: no such file: %s", name)
+	}
+	content, err := s.readAsset(src)
+	if err != nil {
+		return "", err
+	}
+	hashed := fingerprintName(name, content)
+	s.writeFile(filepath.Join(f.outDir, filepath.FromSlash(hashed)), content)
+	f.names[name] = hashed
+	f.urls[name] = s.relURL(hashed)
+	return f.urls[name], nil
+}
+
+func fingerprintName(name string, content []byte) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hashBytes(content)[:8] + ext
+}
+
+// changed reports whether any of the given fingerprinted files,
+// mapped to their fingerprinted names, has changed.
+func (f *fingerprinter) changed(names map[string]string) bool {
+	for name, hashed := range names {
+		src, ok := f.assets[filepath.FromSlash(name)]
+		if !ok {
+			return true
+		}
+		content, err := f.site.readAsset(src)
+		if err != nil || fingerprintName(name, content) != hashed {
+			return true
+		}
+	}
+	return false
+}
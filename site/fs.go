@@ -0,0 +1,292 @@
+package site
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Output is where a site is built to. If it implements fs.FS too,
+// the previous build is read back from it, to write only the files
+// that changed and check the links between them.
+type Output interface {
+	// WriteFile writes data to the file at the slash-separated
+	// path name, creating the directories it's in.
+	WriteFile(name string, data []byte) error
+}
+
+// DirOutput returns the Output writing to the directory dir.
+func DirOutput(dir string) Output {
+	return dirOutput{FS: os.DirFS(dir), dir: dir}
+}
+
+type dirOutput struct {
+	fs.FS
+	dir string
+}
+
+func (o dirOutput) WriteFile(name string, data []byte) error {
+	path := filepath.Join(o.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Remove removes the file at the slash-separated path name,
+// and the directories it leaves empty.
+func (o dirOutput) Remove(name string) error {
+	path := filepath.Join(o.dir, filepath.FromSlash(name))
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	for dir := filepath.Dir(path); isWithin(dir, o.dir) && dir != filepath.Clean(o.dir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// fsName returns the slash-separated name in a file system
+// of the file at path, joined to root.
+func fsName(root, path string) (string, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &fs.PathError{Op: "open", Path: path, Err: fs.ErrInvalid}
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// sourceFile returns the file system holding the file of the site
+// at path and its name there. Files outside the site, such as those
+// of other content directories, are read from disk if the site is.
+func (s *Site) sourceFile(path string) (fs.FS, string, error) {
+	name, err := fsName(s.dir, path)
+	if err == nil || !s.srcOnDisk {
+		return s.src, name, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", err
+	}
+	root := filepath.VolumeName(abs) + string(filepath.Separator)
+	return os.DirFS(root), filepath.ToSlash(strings.TrimPrefix(abs, root)), nil
+}
+
+func (s *Site) readSource(path string) ([]byte, error) {
+	fsys, name, err := s.sourceFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(fsys, name)
+}
+
+func (s *Site) openSource(path string) (fs.File, error) {
+	fsys, name, err := s.sourceFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.Open(name)
+}
+
+func (s *Site) statSource(path string) (fs.FileInfo, error) {
+	fsys, name, err := s.sourceFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(fsys, name)
+}
+
+// walkSource walks the site's files under root like filepath.WalkDir.
+// Symlinks to directories are skipped unless followed.
+func (s *Site) walkSource(root string, fn fs.WalkDirFunc) error {
+	if s.cfg.FollowSymlinks && s.srcOnDisk {
+		real, err := realPath(root)
+		if err != nil {
+			err = fn(root, nil, err)
+		} else {
+			err = s.walkLinks(root, real, nil, fn)
+		}
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	fsys, fsRoot := s.src, s.dir
+	if _, err := fsName(s.dir, root); err != nil && s.srcOnDisk {
+		fsys, fsRoot = os.DirFS(root), root
+	}
+	return walkFS(fsys, fsRoot, root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type()&fs.ModeSymlink != 0 {
+			if info, err := s.statSource(path); err == nil && info.IsDir() {
+				s.logDebug("skipping %s: a symlink to a directory, see followSymlinks", path)
+				return nil
+			}
+		}
+		return fn(path, d, err)
+	})
+}
+
+// walkLinks walks the directory real, reporting its files under
+// root, and the directories symlinks point to as if they were there.
+// parents are the directories of the symlinks followed to get there:
+// symlinks to one of them, or to a directory above the symlink,
+// are skipped as they would loop.
+func (s *Site) walkLinks(root, real string, parents []string, fn fs.WalkDirFunc) error {
+	return filepath.WalkDir(real, func(path string, d fs.DirEntry, err error) error {
+		rel, relErr := filepath.Rel(real, path)
+		if relErr != nil {
+			return relErr
+		}
+		dir := filepath.Dir(path)
+		path = filepath.Join(root, rel)
+		if rel == "." && d != nil {
+			d = namedEntry{d, filepath.Base(root)}
+		}
+		if err != nil || d.Type()&fs.ModeSymlink == 0 {
+			return fn(path, d, err)
+		}
+		target, err := realPath(path)
+		if err != nil {
+			return fn(path, d, err)
+		}
+		info, err := os.Stat(target)
+		if err != nil || !info.IsDir() {
+			return fn(path, d, err)
+		}
+		for _, parent := range append(parents, dir) {
+			if isWithin(parent, target) {
+				s.logWarning("%s: not following the symlink, it loops", path)
+				return nil
+			}
+		}
+		err = s.walkLinks(path, target, append(parents, dir), fn)
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	})
+}
+
+// realPath returns the absolute path of path with symlinks resolved.
+func realPath(path string) (string, error) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// namedEntry is a directory entry under another name,
+// the name of the symlink to it.
+type namedEntry struct {
+	fs.DirEntry
+	name string
+}
+
+func (e namedEntry) Name() string {
+	return e.name
+}
+
+func walkFS(fsys fs.FS, fsRoot, root string, fn fs.WalkDirFunc) error {
+	name, err := fsName(fsRoot, root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	return fs.WalkDir(fsys, name, func(name string, d fs.DirEntry, err error) error {
+		return fn(filepath.Join(fsRoot, filepath.FromSlash(name)), d, err)
+	})
+}
+
+// diskPath returns the path of a file of the site for external
+// commands, failing if the site isn't on disk.
+func (s *Site) diskPath(path string) (string, error) {
+	if !s.srcOnDisk {
+		return "", &fs.PathError{Op: "open", Path: path, Err: errors.New("site is not on disk")}
+	}
+	return path, nil
+}
+
+// outputDiskPath returns the path of a file of the output for
+// external commands, failing if the output isn't on disk.
+func (s *Site) outputDiskPath(path string) (string, error) {
+	if _, ok := s.out.(dirOutput); !ok {
+		return "", &fs.PathError{Op: "open", Path: path, Err: errors.New("output is not on disk")}
+	}
+	return path, nil
+}
+
+// outputInSource reports whether the output may be in the directory
+// of the site, being both on disk.
+func (s *Site) outputInSource() bool {
+	_, ok := s.out.(dirOutput)
+	return ok && s.srcOnDisk
+}
+
+func (s *Site) writeOutput(path string, data []byte) error {
+	name, err := fsName(s.outDir, path)
+	if err != nil {
+		return err
+	}
+	s.outputs.add(path)
+	s.written.add(path)
+	return s.out.WriteFile(name, data)
+}
+
+// removeOutput removes the file at path from the output,
+// failing if the output can't remove files.
+func (s *Site) removeOutput(path string) error {
+	name, err := fsName(s.outDir, path)
+	if err != nil {
+		return err
+	}
+	out, ok := s.out.(interface{ Remove(name string) error })
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: path, Err: errors.New("output can't remove files")}
+	}
+	return out.Remove(name)
+}
+
+// readableOutput returns the output as a file system to read
+// the previous build from, if it is one.
+func (s *Site) readableOutput() (fs.FS, bool) {
+	fsys, ok := s.out.(fs.FS)
+	return fsys, ok
+}
+
+func (s *Site) readOutput(path string) ([]byte, error) {
+	name, err := fsName(s.outDir, path)
+	if err != nil {
+		return nil, err
+	}
+	fsys, ok := s.readableOutput()
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+	}
+	return fs.ReadFile(fsys, name)
+}
+
+func (s *Site) statOutput(path string) (fs.FileInfo, error) {
+	name, err := fsName(s.outDir, path)
+	if err != nil {
+		return nil, err
+	}
+	fsys, ok := s.readableOutput()
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: path, Err: fs.ErrNotExist}
+	}
+	return fs.Stat(fsys, name)
+}
+
+// walkOutput walks the files of the previous build
+// like filepath.WalkDir, if it can be read.
+func (s *Site) walkOutput(fn fs.WalkDirFunc) error {
+	fsys, ok := s.readableOutput()
+	if !ok {
+		return nil
+	}
+	return walkFS(fsys, s.outDir, s.outDir, fn)
+}
@@ -0,0 +1,99 @@
+package site
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// truncate shortens s to at most n characters, ending it with
+// an ellipsis if anything was cut off.
+func truncate(n int, s string) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimRightFunc(string(runes[:n]), unicode.IsSpace) + "…"
+}
+
+// title capitalizes the first letter of every word.
+func title(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		start := unicode.IsSpace(prev)
+		prev = r
+		if start {
+			return unicode.ToTitle(r)
+		}
+		return r
+	}, s)
+}
+
+// markdownify renders a markdown snippet, e.g. from the front matter.
+// A lone paragraph is returned without the surrounding <p> tag.
+func (s *Site) markdownify(text string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := s.newMarkdown(s.cfg.Markdown).Convert([]byte(text), &buf); err != nil {
+		return "", err
+	}
+	html := bytes.TrimSpace(buf.Bytes())
+	if bytes.HasPrefix(html, []byte("<p>")) && bytes.HasSuffix(html, []byte("</p>")) &&
+		bytes.Count(html, []byte("<p>")) == 1 {
+		html = html[3 : len(html)-4]
+	}
+	return template.HTML(html), nil
+}
+
+// renderMarkdown renders a markdown snippet as a whole,
+// unlike markdownify keeping its paragraphs as they are.
+func (s *Site) renderMarkdown(text string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := s.newMarkdown(s.cfg.Markdown).Convert([]byte(text), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// absURL turns a url relative to the site root into an absolute one
+// using the configured baseURL. Absolute urls are returned as is.
+func (s *Site) absURL(link string) string {
+	if u, err := url.Parse(link); err == nil && u.IsAbs() {
+		return link
+	}
+	return s.cfg.absURL(link)
+}
+
+// relURL turns a url relative to the site root into one relative to
+// the host, keeping the path of the configured baseURL.
+func (s *Site) relURL(link string) string {
+	if u, err := url.Parse(link); err == nil && u.IsAbs() {
+		return link
+	}
+	base := "/"
+	if u, err := url.Parse(s.cfg.BaseURL); err == nil && u.Path != "" {
+		base = u.Path
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(link, "/")
+}
+
+// plainify strips the html tags from s, leaving its text
+// with the whitespace collapsed.
+func plainify(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(b.String())), " ")
+}
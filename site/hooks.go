@@ -0,0 +1,85 @@
+package site
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// HooksConfig lists the shell commands run around the build.
+type HooksConfig struct {
+	// Before is run before the site is read, e.g. to generate
+	// static files with a bundler.
+	Before []string `toml:"before" yaml:"before"`
+	// After is run once the site is built, unless it failed.
+	After []string `toml:"after" yaml:"after"`
+}
+
+// runHooks runs the commands one after the other in the site
+// directory, stopping the build at the first failing. They get
+// the site and output directories in MARC_SITE and MARC_OUTPUT,
+// the base url in MARC_BASE_URL, the environment in MARC_ENV
+// and MARC_DEV=1 when serving. A dry run skips them, as they
+// may write anything.
+func (s *Site) runHooks(stage string, commands []string, siteDir, outDir string, cfg Config) {
+	if len(commands) == 0 {
+		return
+	}
+	if cfg.DryRun {
+		s.logDebug("skipping %s hooks in a dry run", stage)
+		return
+	}
+	dir, err := s.diskPath(siteDir)
+	if err != nil {
+		fatalf("%s hooks: %s", stage, err)
+	}
+	if _, err := s.outputDiskPath(outDir); err != nil {
+		fatalf("%s hooks: %s", stage, err)
+	}
+	env := append(os.Environ(),
+		"MARC_SITE="+absPath(siteDir),
+		"MARC_OUTPUT="+absPath(outDir),
+		"MARC_BASE_URL="+cfg.BaseURL,
+		"MARC_ENV="+cfg.Env,
+	)
+	if cfg.Dev {
+		env = append(env, "MARC_DEV=1")
+	}
+	for _, command := range commands {
+		if verbosity >= Normal {
+			s.logf("> %s", command)
+		}
+		cmd := shellCommand(command)
+		cmd.Dir = dir
+		cmd.Env = env
+		out, err := cmd.CombinedOutput()
+		out = bytes.TrimSpace(out)
+		if err != nil {
+			fatalf("%s hook %q failed: %s\n%s", stage, command, err, out)
+		}
+		if len(out) > 0 && verbosity >= Normal {
+			for _, line := range strings.Split(string(out), "\n") {
+				s.logf("  %s", line)
+			}
+		}
+	}
+}
+
+// shellCommand returns the command running command with the shell.
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// absPath returns path made absolute, or as is if that fails.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
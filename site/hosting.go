@@ -0,0 +1,156 @@
+package site
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The hosting platforms whose redirect and header files
+// the platform config has written.
+const (
+	Netlify = "netlify"
+	Vercel  = "vercel"
+)
+
+// Redirect is a redirect of the redirects config.
+type Redirect struct {
+	From string `toml:"from" yaml:"from"`
+	To   string `toml:"to" yaml:"to"`
+	// Status is the HTTP status, 301 by default.
+	Status int `toml:"status" yaml:"status"`
+}
+
+// HeaderRule sets the HTTP headers of the urls matching
+// the path For, where * matches anything.
+type HeaderRule struct {
+	For    string            `toml:"for" yaml:"for"`
+	Values map[string]string `toml:"values" yaml:"values"`
+}
+
+// redirects returns the redirects of the config, then
+// a permanent one for each of the aliases of the pages.
+func (s *Site) redirects(cfg Config, pages Pages) []Redirect {
+	var list []Redirect
+	for _, r := range cfg.Redirects {
+		if r.Status == 0 {
+			r.Status = 301
+		}
+		list = append(list, r)
+	}
+	for _, page := range pages {
+		for _, alias := range pageAliases(page) {
+			list = append(list, Redirect{From: s.relURL(alias), To: s.relURL(page.Url), Status: 301})
+		}
+	}
+	return list
+}
+
+// writeHostingFiles writes the redirects and headers in the files
+// of the platform: _redirects and _headers for Netlify (and
+// Cloudflare Pages), vercel.json for Vercel. Files of the same
+// name in the site win.
+func (s *Site) writeHostingFiles(outDir string, cfg Config, pages Pages, assets map[string]string) {
+	platform := cfg.Platform
+	if platform == "" && cfg.NetlifyRedirects {
+		platform = Netlify
+	}
+	switch platform {
+	case Netlify:
+		s.writeNetlifyFiles(outDir, cfg, pages, assets)
+	case Vercel:
+		s.writeVercelFiles(outDir, cfg, pages, assets)
+	}
+}
+
+func (s *Site) writeNetlifyFiles(outDir string, cfg Config, pages Pages, assets map[string]string) {
+	var b bytes.Buffer
+	if assets["_redirects"] == "" {
+		for _, r := range s.redirects(cfg, pages) {
+			fmt.Fprintf(&b, "%s %s %d\n", r.From, r.To, r.Status)
+		}
+		if b.Len() > 0 {
+			s.writeFile(filepath.Join(outDir, "_redirects"), b.Bytes())
+		}
+	}
+	if assets["_headers"] == "" && len(cfg.Headers) > 0 {
+		b.Reset()
+		for _, rule := range cfg.Headers {
+			fmt.Fprintf(&b, "%s\n", rule.For)
+			for _, name := range headerNames(rule) {
+				fmt.Fprintf(&b, "  %s: %s\n", name, rule.Values[name])
+			}
+		}
+		s.writeFile(filepath.Join(outDir, "_headers"), b.Bytes())
+	}
+}
+
+// vercelConfig is the part of vercel.json marc writes.
+type vercelConfig struct {
+	Redirects []vercelRedirect `json:"redirects,omitempty"`
+	Headers   []vercelHeaders  `json:"headers,omitempty"`
+}
+
+type vercelRedirect struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	StatusCode  int    `json:"statusCode"`
+}
+
+type vercelHeaders struct {
+	Source  string         `json:"source"`
+	Headers []vercelHeader `json:"headers"`
+}
+
+type vercelHeader struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (s *Site) writeVercelFiles(outDir string, cfg Config, pages Pages, assets map[string]string) {
+	if assets["vercel.json"] != "" {
+		return
+	}
+	var vc vercelConfig
+	for _, r := range s.redirects(cfg, pages) {
+		vc.Redirects = append(vc.Redirects, vercelRedirect{
+			Source:      vercelPattern(r.From),
+			Destination: r.To,
+			StatusCode:  r.Status,
+		})
+	}
+	for _, rule := range cfg.Headers {
+		h := vercelHeaders{Source: vercelPattern(rule.For)}
+		for _, name := range headerNames(rule) {
+			h.Headers = append(h.Headers, vercelHeader{Key: name, Value: rule.Values[name]})
+		}
+		vc.Headers = append(vc.Headers, h)
+	}
+	if len(vc.Redirects) == 0 && len(vc.Headers) == 0 {
+		return
+	}
+	data, err := json.MarshalIndent(vc, "", "  ")
+	if err != nil {
+		fatal("failed to write vercel.json:", err)
+	}
+	s.writeFile(filepath.Join(outDir, "vercel.json"), append(data, '\n'))
+}
+
+// vercelPattern turns the * of a path into the
+// path-to-regexp pattern Vercel matches with.
+func vercelPattern(path string) string {
+	return strings.ReplaceAll(path, "*", "(.*)")
+}
+
+// headerNames returns the names of the headers of a rule, sorted.
+func headerNames(rule HeaderRule) []string {
+	names := make([]string, 0, len(rule.Values))
+	for name := range rule.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,110 @@
+package site
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFile lists patterns of files left out of the site,
+// one per line, in addition to the ignore config.
+const ignoreFile = ".marcignore"
+
+// ignoreRule is a glob pattern of files left out of the site.
+// Patterns with a slash match the slash-separated path from the site
+// root, the others the name of any file or directory, and those
+// ending in a slash only match directories.
+type ignoreRule struct {
+	pattern  string
+	anchored bool
+	dirOnly  bool
+}
+
+type ignoreRules []ignoreRule
+
+// readIgnoreRules returns the rules of the ignore config
+// and of the site's .marcignore, if any.
+func (s *Site) readIgnoreRules(siteDir string, cfg Config) ignoreRules {
+	patterns := append(compatIgnores(cfg), cfg.Ignore...)
+	text, err := s.readSource(filepath.Join(siteDir, ignoreFile))
+	if err != nil && !os.IsNotExist(err) {
+		fatalIO("failed to read ignore file:", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return parseIgnoreRules(patterns)
+}
+
+// parseIgnoreRules returns the rules of the patterns.
+func parseIgnoreRules(patterns []string) ignoreRules {
+	var rules ignoreRules
+	for _, pattern := range patterns {
+		rule := ignoreRule{pattern: pattern}
+		if strings.HasSuffix(rule.pattern, "/") {
+			rule.dirOnly = true
+			rule.pattern = strings.TrimSuffix(rule.pattern, "/")
+		}
+		if strings.Contains(rule.pattern, "/") {
+			rule.anchored = true
+			rule.pattern = strings.TrimPrefix(rule.pattern, "/")
+		}
+		if _, err := path.Match(rule.pattern, ""); err != nil || rule.pattern == "" {
+			fatalf("bad pattern %q", pattern)
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// match reports whether the file or directory at relpath,
+// relative to the site root, is ignored.
+func (rules ignoreRules) match(relpath string, isDir bool) bool {
+	relpath = filepath.ToSlash(relpath)
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		name := path.Base(relpath)
+		if rule.anchored {
+			name = relpath
+		}
+		if ok, _ := path.Match(rule.pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// keptNames are the underscore-prefixed files
+// marc or static hosts have a use for.
+var keptNames = map[string]bool{
+	"_redirects": true,
+	"_headers":   true,
+}
+
+// isSkipped reports whether a file or directory is left out of the
+// site by default: hidden (.git, .DS_Store), prefixed with an
+// underscore, other than section lists (_index.md, _index.de.md),
+// Sass partials and the files of static hosts, or an editor backup
+// (page.md~, #page.md#).
+func (s *Site) isSkipped(name string) bool {
+	switch {
+	case isHidden(name):
+		return true
+	case strings.HasPrefix(name, "_"):
+		isSectionList := strings.HasPrefix(name, "_index.") && s.isMarkdown(filepath.Ext(name))
+		return !isSectionList && !keptNames[name] && !isSassPartial(name)
+	case strings.HasSuffix(name, "~"):
+		return true
+	}
+	return len(name) > 1 && strings.HasPrefix(name, "#") && strings.HasSuffix(name, "#")
+}
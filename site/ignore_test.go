@@ -0,0 +1,35 @@
+package site
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	s := newSite(fstest.MapFS{
+		ignoreFile: {Data: []byte("# comment\n\n/drafts/\n*.psd\n")},
+	}, "site", false, nil, "", Config{})
+	rules := s.readIgnoreRules("site", Config{Ignore: []string{"node_modules/", "notes/*.txt"}})
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{path: "node_modules", isDir: true, want: true},
+		{path: "lib/node_modules", isDir: true, want: true},
+		{path: "node_modules", isDir: false, want: false},
+		{path: "notes/todo.txt", want: true},
+		{path: "posts/notes/todo.txt", want: false},
+		{path: "notes/todo.md", want: false},
+		{path: "drafts", isDir: true, want: true},
+		{path: "posts/drafts", isDir: true, want: false},
+		{path: "img/logo.psd", want: true},
+		{path: "img/logo.png", want: false},
+		{path: "comment", want: false},
+	}
+	for _, test := range tests {
+		if got := rules.match(test.path, test.isDir); got != test.want {
+			t.Errorf("match(%q, %v) = %v, want %v", test.path, test.isDir, got, test.want)
+		}
+	}
+}
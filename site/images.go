@@ -0,0 +1,325 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yuin/goldmark/ast"
+	"golang.org/x/image/draw"
+)
+
+// ImagesConfig controls the resized variants of the site's images.
+type ImagesConfig struct {
+	// Widths are the widths in pixels of the variants,
+	// none are made if empty.
+	Widths []int `toml:"widths" yaml:"widths"`
+	// Sizes is the sizes attribute of the images with variants.
+	Sizes   string `toml:"sizes" yaml:"sizes"`
+	Quality int    `toml:"quality" yaml:"quality"`
+	// Formats are the formats, webp or avif, the images and their
+	// variants are converted to, offered in a <picture> element
+	// with the original as the fallback.
+	Formats []string `toml:"formats" yaml:"formats"`
+}
+
+// imageFormats are the commands converting images to each format,
+// given the quality, source and destination.
+var imageFormats = map[string]func(quality int, src, dst string) *exec.Cmd{
+	"webp": func(quality int, src, dst string) *exec.Cmd {
+		return exec.Command("cwebp", "-quiet", "-q", strconv.Itoa(quality), src, "-o", dst)
+	},
+	"avif": func(quality int, src, dst string) *exec.Cmd {
+		return exec.Command("avifenc", "-q", strconv.Itoa(quality), src, dst)
+	},
+}
+
+// imageSet lists the variants made of an image.
+type imageSet struct {
+	// Width is the width of the image itself.
+	Width   int
+	Widths  []int
+	Formats []string
+}
+
+// imageProcessor makes the image variants, set up by build.
+type imageProcessor struct {
+	mu     sync.Mutex
+	site   *Site
+	cfg    ImagesConfig
+	outDir string
+	assets map[string]string
+	sets   map[string]*imageSet
+}
+
+func (p *imageProcessor) reset(site *Site, outDir string, assets map[string]string, cfg ImagesConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.site = site
+	p.cfg = cfg
+	p.outDir = outDir
+	p.assets = assets
+	p.sets = make(map[string]*imageSet)
+}
+
+// variantName returns the name of the variant of the given width,
+// img/photo.480w.jpg for img/photo.jpg, or img/photo.webp with
+// a zero width and a webp format.
+func variantName(name string, width int, format string) string {
+	ext := path.Ext(name)
+	name = strings.TrimSuffix(name, ext)
+	if width > 0 {
+		name += fmt.Sprintf(".%dw", width)
+	}
+	if format != "" {
+		ext = "." + format
+	}
+	return name + ext
+}
+
+// variants makes the variants of a JPEG or PNG file of the site,
+// given by its slash-separated path from the site root. Only widths
+// smaller than the image are made, and variants newer than the image
+// are kept as is.
+func (p *imageProcessor) variants(name string) (*imageSet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.cfg.Widths) == 0 && len(p.cfg.Formats) == 0 {
+		return &imageSet{}, nil
+	}
+	if set, ok := p.sets[name]; ok {
+		return set, nil
+	}
+	set := &imageSet{}
+	src, ok := p.assets[filepath.FromSlash(name)]
+	ext := strings.ToLower(path.Ext(name))
+	if !ok || (ext != ".jpg" && ext != ".jpeg" && ext != ".png") {
+		p.sets[name] = set
+		return set, nil
+	}
+
+	stat, err := p.site.statSource(src)
+	if err != nil {
+		return nil, err
+	}
+	content, err := p.site.readSource(src)
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	set.Width = config.Width
+	var img image.Image
+	for _, width := range p.cfg.Widths {
+		if width <= 0 || width >= config.Width {
+			continue
+		}
+		set.Widths = append(set.Widths, width)
+		outPath := filepath.Join(p.outDir, filepath.FromSlash(variantName(name, width, "")))
+		if p.site.isNewer(outPath, stat.ModTime()) {
+			p.site.outputs.add(outPath)
+			continue
+		}
+		if p.site.dryRun {
+			p.site.wouldMake(outPath)
+			continue
+		}
+		if img == nil {
+			if img, _, err = image.Decode(bytes.NewReader(content)); err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err)
+			}
+		}
+		if err := p.site.writeVariant(outPath, img, width, p.cfg.Quality); err != nil {
+			return nil, err
+		}
+	}
+
+	quality := p.cfg.Quality
+	if quality <= 0 {
+		quality = jpeg.DefaultQuality
+	}
+	for _, format := range p.cfg.Formats {
+		convert, ok := imageFormats[format]
+		if !ok {
+			return nil, fmt.Errorf("unknown image format %q", format)
+		}
+		set.Formats = append(set.Formats, format)
+		for _, width := range append([]int{0}, set.Widths...) {
+			in, err := p.site.diskPath(src)
+			if width > 0 {
+				in, err = p.site.outputDiskPath(filepath.Join(p.outDir, filepath.FromSlash(variantName(name, width, ""))))
+			}
+			if err != nil {
+				return nil, err
+			}
+			outPath := filepath.Join(p.outDir, filepath.FromSlash(variantName(name, width, format)))
+			if p.site.isNewer(outPath, stat.ModTime()) {
+				p.site.outputs.add(outPath)
+				continue
+			}
+			if p.site.dryRun {
+				p.site.wouldMake(outPath)
+				continue
+			}
+			if _, err := p.site.outputDiskPath(outPath); err != nil {
+				return nil, err
+			}
+			if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+				return nil, err
+			}
+			p.site.logOutput(outPath)
+			if out, err := convert(quality, in, outPath).CombinedOutput(); err != nil {
+				return nil, fmt.Errorf("%s: %s %s", name, err, bytes.TrimSpace(out))
+			}
+			p.site.outputs.add(outPath)
+		}
+	}
+	p.sets[name] = set
+	return set, nil
+}
+
+// isNewer reports whether the file at path exists and was
+// modified after t.
+func (s *Site) isNewer(path string, t time.Time) bool {
+	stat, err := s.statOutput(path)
+	return err == nil && stat.ModTime().After(t)
+}
+
+func (s *Site) writeVariant(outPath string, img image.Image, width, quality int) error {
+	bounds := img.Bounds()
+	height := bounds.Dy() * width / bounds.Dx()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	var err error
+	if ext := strings.ToLower(filepath.Ext(outPath)); ext == ".png" {
+		err = png.Encode(&buf, dst)
+	} else {
+		if quality <= 0 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: quality})
+	}
+	if err != nil {
+		return err
+	}
+	s.logOutput(outPath)
+	return s.writeOutput(outPath, buf.Bytes())
+}
+
+// imageName returns the path from the site root of an image
+// referenced from a page, or "" for remote images.
+func imageName(dest string, page *Page) string {
+	u, err := url.Parse(dest)
+	if err != nil || u.IsAbs() || u.Host != "" || u.Path == "" {
+		return ""
+	}
+	if strings.HasPrefix(u.Path, "/") {
+		return path.Clean(strings.TrimPrefix(u.Path, "/"))
+	}
+	return path.Join(path.Dir(filepath.ToSlash(page.RelPath)), u.Path)
+}
+
+// srcset returns the srcset of the image and its variants in a
+// format, or in the format of the image if "", the variant urls made
+// from the url of the image. Without widths it's the url of the
+// image in the format.
+func (set *imageSet) srcset(dest string, format string) string {
+	if len(set.Widths) == 0 {
+		return variantName(dest, 0, format)
+	}
+	var candidates []string
+	for _, width := range set.Widths {
+		candidates = append(candidates, fmt.Sprintf("%s %dw", variantName(dest, width, format), width))
+	}
+	candidates = append(candidates, fmt.Sprintf("%s %dw", variantName(dest, 0, format), set.Width))
+	return strings.Join(candidates, ", ")
+}
+
+// sources returns the <source> elements of the formats of an image.
+func (set *imageSet) sources(dest string, sizes string) string {
+	var b strings.Builder
+	for _, format := range set.Formats {
+		fmt.Fprintf(&b, `<source type="image/%s" srcset="%s"`, format, html.EscapeString(set.srcset(dest, format)))
+		if sizes != "" && len(set.Widths) > 0 {
+			fmt.Fprintf(&b, ` sizes="%s"`, html.EscapeString(sizes))
+		}
+		b.WriteString(">")
+	}
+	return b.String()
+}
+
+// processImages adds the srcset and sizes attributes of the variants
+// to the images of a page, and wraps those converted to other formats
+// in a <picture> element.
+func (s *Site) processImages(doc ast.Node, page *Page) error {
+	return ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		img, ok := n.(*ast.Image)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		dest := string(img.Destination)
+		name := imageName(dest, page)
+		if name == "" {
+			return ast.WalkContinue, nil
+		}
+		set, err := s.images.variants(name)
+		if err != nil {
+			return ast.WalkContinue, err
+		}
+		if len(set.Widths) > 0 {
+			img.SetAttributeString("srcset", []byte(set.srcset(dest, "")))
+			if s.images.cfg.Sizes != "" {
+				img.SetAttributeString("sizes", []byte(s.images.cfg.Sizes))
+			}
+		}
+		if len(set.Formats) > 0 {
+			// code strings are rendered as is
+			open := ast.NewString([]byte("<picture>" + set.sources(dest, s.images.cfg.Sizes)))
+			open.SetCode(true)
+			closing := ast.NewString([]byte("</picture>"))
+			closing.SetCode(true)
+			parent := img.Parent()
+			parent.InsertBefore(parent, img, open)
+			parent.InsertAfter(parent, img, closing)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// srcset returns the srcset of a static image of the site given by
+// its path from the site root, or "" if it has no variants.
+func (s *Site) srcset(name string) (string, error) {
+	set, err := s.images.variants(path.Clean(strings.TrimPrefix(name, "/")))
+	if err != nil || len(set.Widths) == 0 {
+		return "", err
+	}
+	return set.srcset(s.relURL(name), ""), nil
+}
+
+// sources returns the <source> elements of the formats a static
+// image of the site is converted to, for a <picture> element.
+func (s *Site) sources(name string) (template.HTML, error) {
+	set, err := s.images.variants(path.Clean(strings.TrimPrefix(name, "/")))
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(set.sources(s.relURL(name), s.images.cfg.Sizes)), nil
+}
@@ -0,0 +1,106 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// includer is used by the readFile, inlineCSS and inlineJS template
+// functions, set up by build. The files read are recorded for the
+// build cache, which rebuilds the site when one of them changes.
+type includer struct {
+	mu     sync.Mutex
+	site   *Site
+	assets map[string]string
+	// hashes maps the files read to the hash of their content.
+	hashes map[string]string
+}
+
+func (in *includer) reset(site *Site, assets map[string]string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.site = site
+	in.assets = assets
+	in.hashes = make(map[string]string)
+}
+
+// read returns the content of a file of the site, given by its path
+// from the site root: a static file, Sass stylesheets being compiled
+// to the CSS they are written as, or else any file of the site.
+func (in *includer) read(name string) ([]byte, error) {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	content, err := in.content(name)
+	if err != nil {
+		return nil, err
+	}
+	in.mu.Lock()
+	if in.hashes != nil {
+		in.hashes[name] = hashBytes(content)
+	}
+	in.mu.Unlock()
+	return content, nil
+}
+
+func (in *includer) content(name string) ([]byte, error) {
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return nil, fmt.Errorf("%s is outside the site", name)
+	}
+	in.mu.Lock()
+	src, ok := in.assets[filepath.FromSlash(name)]
+	in.mu.Unlock()
+	if ok {
+		return in.site.readAsset(src)
+	}
+	content, err := in.site.readSource(filepath.Join(in.site.dir, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, fmt.Errorf("no such file: %s", name)
+	}
+	return content, nil
+}
+
+// changed reports whether any of the given files read,
+// mapped to the hashes of their content, has changed.
+func (in *includer) changed(hashes map[string]string) bool {
+	for name, hash := range hashes {
+		content, err := in.content(name)
+		if err != nil || hashBytes(content) != hash {
+			return true
+		}
+	}
+	return false
+}
+
+// readFile returns the content of a file of the site,
+// e.g. {{ readFile "snippets/note.md" | renderMarkdown }}.
+func (s *Site) readFile(name string) (string, error) {
+	content, err := s.includes.read(name)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// inlineCSS returns the content of a stylesheet of the site
+// to inline in a <style> element, such as critical CSS.
+func (s *Site) inlineCSS(name string) (template.CSS, error) {
+	content, err := s.includes.read(name)
+	if err != nil {
+		return "", err
+	}
+	return template.CSS(bytes.TrimSpace(content)), nil
+}
+
+// inlineJS returns the content of a script of the site
+// to inline in a <script> element.
+func (s *Site) inlineJS(name string) (template.JS, error) {
+	content, err := s.includes.read(name)
+	if err != nil {
+		return "", err
+	}
+	return template.JS(bytes.TrimSpace(content)), nil
+}
@@ -0,0 +1,20 @@
+package site
+
+// IndieWebConfig sets the IndieWeb endpoints the pages advertise,
+// in the indieweb partial, and the microformats of the default
+// template.
+type IndieWebConfig struct {
+	Webmention string `toml:"webmention" yaml:"webmention"`
+	Pingback   string `toml:"pingback" yaml:"pingback"`
+	Micropub   string `toml:"micropub" yaml:"micropub"`
+	// AuthorizationEndpoint and TokenEndpoint are for
+	// signing in to IndieAuth with the site's url.
+	AuthorizationEndpoint string `toml:"authorizationEndpoint" yaml:"authorizationEndpoint"`
+	TokenEndpoint         string `toml:"tokenEndpoint" yaml:"tokenEndpoint"`
+	// Me are the urls of the author's profiles elsewhere,
+	// linked with rel="me".
+	Me []string `toml:"me" yaml:"me"`
+	// HEntry has the default template mark up dated pages
+	// with the hentry partial.
+	HEntry bool `toml:"hentry" yaml:"hentry"`
+}
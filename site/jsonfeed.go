@@ -0,0 +1,86 @@
+package site
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"time"
+)
+
+// JSON Feed 1.1, https://www.jsonfeed.org/version/1.1/
+
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Authors     []jsonFeedAuthor `json:"authors,omitempty"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html"`
+	Summary       string           `json:"summary,omitempty"`
+	Image         string           `json:"image,omitempty"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+	Language      string           `json:"language,omitempty"`
+}
+
+// writeJSONFeed writes a JSON Feed of the pages.
+func (s *Site) writeJSONFeed(outDir string, cfg Config, info feedInfo, pages Pages) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       info.title,
+		HomePageURL: cfg.absURL(info.dir),
+		FeedURL:     cfg.absURL(info.dir + info.file),
+		Items:       make([]jsonFeedItem, 0, len(pages)),
+	}
+	if cfg.Author != "" {
+		feed.Authors = []jsonFeedAuthor{{Name: cfg.Author}}
+	}
+
+	for _, page := range pages {
+		date, _ := page.date()
+		item := jsonFeedItem{
+			ID:            cfg.absURL(page.Url),
+			URL:           cfg.absURL(page.Url),
+			Title:         page.feedTitle(),
+			ContentHTML:   string(page.content()),
+			Summary:       plainify(string(page.Summary)),
+			DatePublished: date.Format(time.RFC3339),
+			Tags:          page.Tags,
+			Language:      page.Lang,
+		}
+		for _, author := range page.Authors {
+			item.Authors = append(item.Authors, jsonFeedAuthor{Name: author.Name})
+		}
+		if page.updated().After(date) {
+			item.DateModified = page.updated().Format(time.RFC3339)
+		}
+		if image := page.metaString("image"); image != "" {
+			item.Image = s.absURL(image)
+		} else if page.Card != "" {
+			item.Image = cfg.absURL(page.Card)
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		fatal("failed to render feed:", err)
+	}
+	s.writeFile(filepath.Join(outDir, filepath.FromSlash(info.dir), info.file), buf.Bytes())
+}
@@ -0,0 +1,62 @@
+package site
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Language holds the settings of one of the site's languages.
+type Language struct {
+	// Code is the key of the language in the config.
+	Code    string            `toml:"-" yaml:"-"`
+	Name    string            `toml:"name" yaml:"name"`
+	Title   string            `toml:"title" yaml:"title"`
+	Strings map[string]string `toml:"strings" yaml:"strings"`
+}
+
+// language returns the settings of the language with the given code,
+// falling back to the site title.
+func (c Config) language(code string) Language {
+	lang := c.Languages[code]
+	lang.Code = code
+	if lang.Title == "" {
+		lang.Title = c.Title
+	}
+	return lang
+}
+
+// pageLang returns the language of a page from its file name,
+// e.g. "de" for post.de.md, if the language is configured,
+// or else the default language.
+func pageLang(relpath string, cfg Config) string {
+	name := strings.TrimSuffix(filepath.Base(relpath), filepath.Ext(relpath))
+	if ext := filepath.Ext(name); ext != "" {
+		if _, ok := cfg.Languages[ext[1:]]; ok {
+			return ext[1:]
+		}
+	}
+	return cfg.DefaultLanguage
+}
+
+// linkTranslations sets the Translations of the pages that
+// exist in other languages under the same path.
+func linkTranslations(pages Pages) {
+	groups := make(map[string][]*Page)
+	for i := range pages {
+		page := &pages[i]
+		if page.Lang == "" {
+			continue
+		}
+		key := filepath.Join(filepath.Dir(page.RelPath), page.filename())
+		groups[key] = append(groups[key], page)
+	}
+	for _, group := range groups {
+		for _, page := range group {
+			for _, other := range group {
+				if other != page {
+					page.Translations = append(page.Translations, other)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package site
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// setLastMod sets the time the pages were last modified: their
+// lastmod front matter field, or else with gitInfo the time of their
+// last commit, or else their date, or else the file's mtime.
+func (s *Site) setLastMod(pages Pages, roots []contentRoot, cfg Config) {
+	// commit times by path relative to the content directory
+	commits := make(map[string]map[string]time.Time)
+	if cfg.GitInfo {
+		for _, root := range roots {
+			var err error
+			if commits[root.dir], err = s.gitCommitTimes(root.dir); err != nil {
+				s.logWarning("failed to read git history: %s", err)
+			}
+		}
+	}
+	for i := range pages {
+		page := &pages[i]
+		if t, err := page.metaDate("lastmod"); err == nil {
+			page.LastMod = t
+			continue
+		}
+		if t, ok := commitTime(commits, roots, page.AbsPath); ok {
+			page.LastMod = t.In(s.dates.location)
+			continue
+		}
+		if t, err := page.date(); err == nil && !cfg.GitInfo {
+			page.LastMod = t
+			continue
+		}
+		if stat, err := s.statSource(page.AbsPath); err == nil {
+			page.LastMod = stat.ModTime().In(s.dates.location)
+			if epoch, ok := sourceDate(); ok && page.LastMod.After(epoch) {
+				page.LastMod = epoch.In(s.dates.location)
+			}
+		}
+	}
+}
+
+// commitTime returns the time of the last commit of the file
+// at path in the git history of its content directory.
+func commitTime(commits map[string]map[string]time.Time, roots []contentRoot, path string) (time.Time, bool) {
+	for _, root := range roots {
+		if name, err := fsName(root.dir, path); err == nil {
+			t, ok := commits[root.dir][name]
+			return t, ok
+		}
+	}
+	return time.Time{}, false
+}
+
+// sourceDate returns the time set in seconds since the Unix epoch
+// by SOURCE_DATE_EPOCH, if it is, for reproducible builds: it is
+// the current time of the build and the latest mtime of the pages.
+func sourceDate() (time.Time, bool) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		fatalf("invalid SOURCE_DATE_EPOCH %q", epoch)
+	}
+	return time.Unix(sec, 0).UTC(), true
+}
+
+// gitCommitTimes returns the time of the last commit of each file
+// under siteDir, by slash-separated path relative to it.
+func (s *Site) gitCommitTimes(siteDir string) (map[string]time.Time, error) {
+	dir, err := s.diskPath(siteDir)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("git", "-c", "core.quotePath=false", "log",
+		"--pretty=format:%x00%ct", "--name-only", "--relative")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	times := make(map[string]time.Time)
+	var t time.Time
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		switch {
+		case len(line) == 0:
+		case line[0] == 0:
+			sec, err := strconv.ParseInt(string(line[1:]), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			t = time.Unix(sec, 0).UTC()
+		default:
+			// the log starts with the latest commits
+			if _, ok := times[string(line)]; !ok {
+				times[string(line)] = t
+			}
+		}
+	}
+	return times, nil
+}
+
+// updated returns the time the page was last updated for feeds,
+// its date unless modified later.
+func (p Page) updated() time.Time {
+	date, _ := p.date()
+	if p.LastMod.After(date) {
+		return p.LastMod
+	}
+	return date
+}
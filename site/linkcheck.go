@@ -0,0 +1,159 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+	parsehtml "github.com/tdewolff/parse/v2/html"
+)
+
+// brokenLink is an internal link or image of an output file
+// that doesn't resolve to a file in the output directory.
+type brokenLink struct {
+	// File and Line locate the link in the page's source
+	// if found there, or else in the output file.
+	File string
+	Line int
+	URL  string
+}
+
+func (l brokenLink) String() string {
+	return fmt.Sprintf("%s:%d: broken link %s", l.File, l.Line, l.URL)
+}
+
+// checkLinks returns the broken href and src links of the html
+// files in the output directory, or of those for which only, if
+// not nil, reports true.
+func (s *Site) checkLinks(outDir string, cfg Config, pages Pages, only func(path string) bool) ([]brokenLink, error) {
+	sources := make(map[string]Page)
+	for _, page := range pages {
+		sources[page.outPath(outDir)] = page
+	}
+
+	var broken []brokenLink
+	err := s.walkOutput(func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".html" || only != nil && !only(path) {
+			return err
+		}
+		content, err := s.readOutput(path)
+		if err != nil {
+			return err
+		}
+		var source []byte
+		page, ok := sources[path]
+		if ok {
+			source, _ = s.readSource(page.AbsPath)
+		}
+		for _, link := range htmlLinks(content) {
+			if s.resolveLink(outDir, path, link.URL, cfg) {
+				continue
+			}
+			if i := bytes.Index(source, []byte(link.URL)); i != -1 {
+				link.File = page.RelPath
+				link.Line = bytes.Count(source[:i], []byte("\n")) + 1
+			} else {
+				link.File = path
+			}
+			broken = append(broken, link)
+		}
+		return nil
+	})
+	sort.SliceStable(broken, func(i, j int) bool {
+		if broken[i].File != broken[j].File {
+			return broken[i].File < broken[j].File
+		}
+		return broken[i].Line < broken[j].Line
+	})
+	return broken, err
+}
+
+// htmlLinks returns the href and src attributes of an html
+// document, with their line in it.
+func htmlLinks(content []byte) []brokenLink {
+	var links []brokenLink
+	line := 1
+	l := parsehtml.NewLexer(parse.NewInputBytes(content))
+	for {
+		tt, data := l.Next()
+		if tt == parsehtml.ErrorToken {
+			return links
+		}
+		if tt == parsehtml.AttributeToken {
+			name := string(bytes.ToLower(l.Text()))
+			if name == "href" || name == "src" {
+				value := bytes.Trim(l.AttrVal(), `"'`)
+				links = append(links, brokenLink{Line: line, URL: html.UnescapeString(string(value))})
+			}
+		}
+		line += bytes.Count(data, []byte("\n"))
+	}
+}
+
+// resolveLink reports whether a link of the output file at path
+// is external or resolves to a file in the output directory.
+// Root-relative links and links to the baseURL are resolved
+// from the path of the baseURL.
+func (s *Site) resolveLink(outDir, path string, link string, cfg Config) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	if isExternal(u, cfg) {
+		return true
+	}
+	base, _ := url.Parse(cfg.BaseURL)
+	if !u.IsAbs() && u.Host == "" && u.Path == "" {
+		// a fragment or query of the page itself
+		return true
+	}
+
+	var target string
+	if strings.HasPrefix(u.Path, "/") {
+		rel := u.Path
+		if base != nil {
+			rel = strings.TrimPrefix(rel, strings.TrimSuffix(base.Path, "/"))
+		}
+		target = filepath.Join(outDir, filepath.FromSlash(rel))
+	} else {
+		target = filepath.Join(filepath.Dir(path), filepath.FromSlash(u.Path))
+	}
+	stat, err := s.statOutput(target)
+	if err == nil && stat.IsDir() {
+		stat, err = s.statOutput(filepath.Join(target, "index.html"))
+	}
+	return err == nil && !stat.IsDir()
+}
+
+// isExternal reports whether a link points outside of the site,
+// links to the baseURL being internal.
+func isExternal(u *url.URL, cfg Config) bool {
+	if !u.IsAbs() && u.Host == "" {
+		return false
+	}
+	base, err := url.Parse(cfg.BaseURL)
+	return err != nil || u.Host != base.Host || u.Scheme != "" && u.Scheme != base.Scheme
+}
+
+// reportBrokenLinks logs the broken links of the files the build
+// wrote, returning an error if there are any and FailOnBrokenLinks
+// is set.
+func (s *Site) reportBrokenLinks(outDir string, cfg Config, pages Pages) error {
+	broken, err := s.checkLinks(outDir, cfg, pages, s.written.has)
+	if err != nil {
+		return err
+	}
+	for _, link := range broken {
+		s.logWarning("%s", link)
+	}
+	if len(broken) > 0 && cfg.FailOnBrokenLinks {
+		return fmt.Errorf("%d broken links", len(broken))
+	}
+	return nil
+}
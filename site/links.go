@@ -0,0 +1,105 @@
+package site
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// pageURLs maps the slash-separated paths of the pages
+// to their urls, for rewriteLinks.
+func pageURLs(pages Pages) map[string]string {
+	urls := make(map[string]string, len(pages))
+	for _, page := range pages {
+		urls[filepath.ToSlash(page.RelPath)] = page.Url
+	}
+	return urls
+}
+
+// rewriteLinks replaces the links of a page to markdown files,
+// relative to the page (other.md, ../posts/hello.md#intro) or to the
+// site root (/posts/hello.md), with the urls of the linked pages.
+// Links to missing pages are left as is and reported.
+func (s *Site) rewriteLinks(doc ast.Node, page *Page, urls map[string]string) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		link, ok := n.(*ast.Link)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		u, err := url.Parse(string(link.Destination))
+		if err != nil || u.IsAbs() || u.Host != "" || !s.isMarkdown(path.Ext(u.Path)) {
+			return ast.WalkContinue, nil
+		}
+		name := path.Join(path.Dir(filepath.ToSlash(page.RelPath)), u.Path)
+		if strings.HasPrefix(u.Path, "/") {
+			name = path.Clean(strings.TrimPrefix(u.Path, "/"))
+		}
+		target, ok := urls[name]
+		if !ok {
+			s.logWarning("%s: link to missing page %s", page.RelPath, u.Path)
+			return ast.WalkContinue, nil
+		}
+		u.Path = s.relURL(target)
+		link.Destination = []byte(u.String())
+		return ast.WalkContinue, nil
+	})
+}
+
+// pageLinks returns the site-relative urls of the internal links
+// of a page, resolved from the page's url.
+func (s *Site) pageLinks(doc ast.Node, page *Page) []string {
+	var links []string
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		link, ok := n.(*ast.Link)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		u, err := url.Parse(string(link.Destination))
+		if err != nil || u.IsAbs() || u.Host != "" || u.Path == "" {
+			return ast.WalkContinue, nil
+		}
+		if strings.HasPrefix(u.Path, "/") {
+			base := "/"
+			if b, err := url.Parse(s.cfg.BaseURL); err == nil && b.Path != "" {
+				base = b.Path
+			}
+			links = append(links, strings.TrimPrefix(u.Path, strings.TrimSuffix(base, "/")+"/"))
+		} else {
+			dir := page.Url
+			if !strings.HasSuffix(dir, "/") {
+				dir = path.Dir(dir)
+			}
+			links = append(links, path.Join(dir, u.Path))
+		}
+		return ast.WalkContinue, nil
+	})
+	return links
+}
+
+// collectBacklinks sets the backlinks of the pages
+// from the links of the other pages.
+func collectBacklinks(pages Pages) {
+	byURL := make(map[string]*Page)
+	for i := range pages {
+		byURL[strings.TrimSuffix(pages[i].Url, "/")] = &pages[i]
+	}
+	for i := range pages {
+		page := &pages[i]
+		seen := make(map[*Page]bool)
+		for _, link := range page.links {
+			link = strings.TrimSuffix(strings.TrimSuffix(link, "index.html"), "/")
+			if link == "." {
+				link = ""
+			}
+			target, ok := byURL[link]
+			if !ok || target == page || seen[target] {
+				continue
+			}
+			seen[target] = true
+			target.Backlinks = append(target.Backlinks, page)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package site
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+func TestRewriteLinks(t *testing.T) {
+	urls := pageURLs(Pages{
+		{RelPath: "index.md", Url: ""},
+		{RelPath: "about.md", Url: "about/"},
+		{RelPath: "posts/hello.md", Url: "posts/hello/"},
+		{RelPath: "posts/world.md", Url: "posts/world.html"},
+	})
+	tests := []struct {
+		name string
+		page string
+		dest string
+		want string
+	}{
+		{name: "sibling", page: "posts/hello.md", dest: "world.md", want: "/posts/world.html"},
+		{name: "parent", page: "posts/hello.md", dest: "../about.md", want: "/about/"},
+		{name: "fragment", page: "posts/hello.md", dest: "../about.md#team", want: "/about/#team"},
+		{name: "query", page: "about.md", dest: "posts/hello.md?x=1", want: "/posts/hello/?x=1"},
+		{name: "site root", page: "posts/hello.md", dest: "/index.md", want: "/"},
+		{name: "missing page", page: "about.md", dest: "missing.md", want: "missing.md"},
+		{name: "not markdown", page: "about.md", dest: "posts/photo.jpg", want: "posts/photo.jpg"},
+		{name: "absolute", page: "about.md", dest: "https://example.com/a.md", want: "https://example.com/a.md"},
+		{name: "host relative", page: "about.md", dest: "//example.com/a.md", want: "//example.com/a.md"},
+	}
+	s := newSite(fstest.MapFS{}, ".", false, nil, "", defaultConfig())
+	for _, test := range tests {
+		src := []byte("[link](" + test.dest + ")")
+		doc := goldmark.New().Parser().Parse(text.NewReader(src))
+		page := &Page{RelPath: test.page}
+		s.rewriteLinks(doc, page, urls)
+		var got []string
+		ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if link, ok := n.(*ast.Link); ok && entering {
+				got = append(got, string(link.Destination))
+			}
+			return ast.WalkContinue, nil
+		})
+		if !reflect.DeepEqual(got, []string{test.want}) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	s.cfg.BaseURL = "https://example.com/blog/"
+	src := []byte("[link](about.md)")
+	doc := goldmark.New().Parser().Parse(text.NewReader(src))
+	s.rewriteLinks(doc, &Page{RelPath: "index.md"}, urls)
+	if link := doc.FirstChild().FirstChild().(*ast.Link); string(link.Destination) != "/blog/about/" {
+		t.Errorf("under a base path: got %q, want %q", link.Destination, "/blog/about/")
+	}
+}
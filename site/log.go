@@ -0,0 +1,191 @@
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The levels of logging, Quiet logging only warnings and errors,
+// and Verbose details of the build too.
+const (
+	Quiet = iota - 1
+	Normal
+	Verbose
+)
+
+// verbosity is the level of logging, set by SetLogging.
+var verbosity = Normal
+
+// jsonLogs is set to log a JSON object per line instead of text.
+var jsonLogs bool
+
+// logEvent is a line of the JSON logs. Level is one of debug, info,
+// warning or error, and Event one of write (of an output file),
+// create or change (of an output file in a dry run), page (failing
+// to build), message or build (its statistics, once done). Site is
+// the name of the site logging it, if set with SetName.
+type logEvent struct {
+	Time    time.Time   `json:"time"`
+	Level   string      `json:"level"`
+	Event   string      `json:"event"`
+	Site    string      `json:"site,omitempty"`
+	Path    string      `json:"path,omitempty"`
+	Message string      `json:"message,omitempty"`
+	Stats   *buildStats `json:"stats,omitempty"`
+}
+
+var logMu sync.Mutex
+
+func (e logEvent) write() {
+	e.Time = time.Now()
+	line, err := json.Marshal(e)
+	if err != nil {
+		panic(err)
+	}
+	logMu.Lock()
+	defer logMu.Unlock()
+	os.Stderr.Write(append(line, '\n'))
+}
+
+// jsonLogWriter turns the lines logged with the log package
+// into info messages.
+type jsonLogWriter struct{}
+
+func (jsonLogWriter) Write(p []byte) (int, error) {
+	logEvent{Level: "info", Event: "message", Message: strings.TrimSuffix(string(p), "\n")}.write()
+	return len(p), nil
+}
+
+// SetLogging sets the level of logging and its format,
+// text or json. The logs go to the standard logger.
+func SetLogging(level int, format string) error {
+	switch format {
+	case "text":
+		jsonLogs = false
+		log.SetOutput(os.Stderr)
+	case "json":
+		jsonLogs = true
+		log.SetOutput(jsonLogWriter{})
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	verbosity = level
+	return nil
+}
+
+// SetName names the site in its logs, to tell it apart from the
+// other sites built at once: its lines of text are prefixed with
+// [name], and its JSON events have the name as site.
+func (s *Site) SetName(name string) {
+	s.name = name
+}
+
+// writeEvent writes an event of the JSON logs of the site.
+func (s *Site) writeEvent(e logEvent) {
+	e.Site = s.name
+	e.write()
+}
+
+// logf logs a line of text, as an info message in the JSON logs.
+func (s *Site) logf(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	if jsonLogs {
+		s.writeEvent(logEvent{Level: "info", Event: "message", Message: msg})
+		return
+	}
+	if s.name != "" {
+		msg = "[" + s.name + "] " + msg
+	}
+	log.Print(msg)
+}
+
+// logOutput logs a file written to the output.
+func (s *Site) logOutput(path string) {
+	if verbosity < Normal {
+		return
+	}
+	if jsonLogs {
+		s.writeEvent(logEvent{Level: "info", Event: "write", Path: path})
+		return
+	}
+	s.logf("* %s", path)
+}
+
+// logDebug logs a detail shown with -verbose.
+func (s *Site) logDebug(format string, v ...interface{}) {
+	if verbosity < Verbose {
+		return
+	}
+	if jsonLogs {
+		s.writeEvent(logEvent{Level: "debug", Event: "message", Message: fmt.Sprintf(format, v...)})
+		return
+	}
+	s.logf(format, v...)
+}
+
+// logWarning logs a problem not stopping the build.
+func (s *Site) logWarning(format string, v ...interface{}) {
+	if jsonLogs {
+		s.writeEvent(logEvent{Level: "warning", Event: "message", Message: fmt.Sprintf(format, v...)})
+		return
+	}
+	s.logf(format, v...)
+}
+
+// LogError logs an error, such as one returned by Build.
+func LogError(err error) {
+	new(Site).LogError(err)
+}
+
+// LogError logs an error of the site, named as set by SetName.
+func (s *Site) LogError(err error) {
+	if jsonLogs {
+		s.writeEvent(logEvent{Level: "error", Event: "message", Message: err.Error()})
+		return
+	}
+	s.logf("%s", err)
+}
+
+// Error is the error of a failed build. IO is set if reading
+// or writing files failed, rather than the site having problems:
+// in its content, templates or config.
+type Error struct {
+	Message string
+	IO      bool
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// fatal stops the build for a problem with the site,
+// recovered as an *Error by the exported functions.
+func fatal(v ...interface{}) {
+	panic(&Error{Message: fmt.Sprint(v...)})
+}
+
+func fatalf(format string, v ...interface{}) {
+	panic(&Error{Message: fmt.Sprintf(format, v...)})
+}
+
+// fatalIO stops the build for a failure to read or write a file.
+func fatalIO(v ...interface{}) {
+	panic(&Error{Message: fmt.Sprint(v...), IO: true})
+}
+
+// recoverError sets err to the *Error a function stopped with,
+// deferred by the exported functions. Other panics go on.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(*Error)
+		if !ok {
+			panic(r)
+		}
+		*err = e
+	}
+}
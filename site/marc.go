@@ -0,0 +1,1185 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"gopkg.in/yaml.v3"
+)
+
+type Page struct {
+	Meta    map[string]interface{}
+	Tags    []string
+	TOC     TOC
+	Text    []byte
+	Url     string
+	HTML    template.HTML
+	Summary template.HTML
+	AbsPath string
+	RelPath string
+	// Section is the top-level directory of the page,
+	// empty for pages in the site root.
+	Section string
+	// Lang is the language code of the page, and Translations
+	// the same page in the other languages.
+	Lang         string
+	Translations []*Page
+	// Card is the url of the page's social card image, if enabled.
+	Card string
+	// WordCount is the number of words in the page text,
+	// ReadingTime the minutes it takes to read them.
+	WordCount   int
+	ReadingTime int
+	// Mermaid reports whether the page has mermaid diagrams.
+	Mermaid bool
+	// Date is the time of the date front matter field,
+	// zero if it has none.
+	Date time.Time
+	// LastMod is the time the page was last modified.
+	LastMod time.Time
+	// Authors are the authors named by the authors front matter
+	// field, or the author one, and Author the first of them.
+	Authors []*Author
+	Author  *Author
+	// Series is the series the page is part of, if any.
+	Series *Series
+	// Resources are the files of the page's bundle, if it is
+	// the index.md of one.
+	Resources Resources
+
+	Prev          *Page
+	Next          *Page
+	PrevInSection *Page
+	NextInSection *Page
+	Related       []*Page
+	Breadcrumbs   []Breadcrumb
+	// Backlinks are the pages linking to the page.
+	Backlinks []*Page
+
+	// links are the site-relative urls the page's text links to.
+	links []string
+	// textHash is the hash of the text, with its shortcodes expanded.
+	textHash string
+	// dates are those of the page's site, nil for pages read
+	// without one, whose dates are in UTC.
+	dates *dateConfig
+}
+
+// metaString returns the front matter value as a string,
+// or an empty string if the key is missing.
+func (p Page) metaString(key string) string {
+	switch v := p.Meta[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// layout returns the file name of the template chosen
+// with the layout (or template) front matter field, if any.
+func (p Page) layout() string {
+	name := p.metaString("layout")
+	if name == "" {
+		name = p.metaString("template")
+	}
+	if name != "" && filepath.Ext(name) != ".tmpl" {
+		name += ".tmpl"
+	}
+	return name
+}
+
+// sectionLayout returns the path of the _layout.tmpl closest to
+// the page in its directory or the ones above, not counting the
+// site directory itself, whose default template is base.tmpl.
+func (s *Site) sectionLayout(siteDir, relpath string) string {
+	for dir := filepath.Dir(relpath); dir != "."; dir = filepath.Dir(dir) {
+		name := filepath.Join(dir, "_layout.tmpl")
+		if _, err := s.statSource(filepath.Join(siteDir, name)); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// isIndex reports whether the page is the index of its directory.
+func (p Page) isIndex() bool {
+	return p.filename() == "index" || p.isSectionList()
+}
+
+// isSectionList reports whether the page is an _index.md,
+// listing the pages of its directory.
+func (p Page) isSectionList() bool {
+	return p.filename() == "_index"
+}
+
+// children returns the pages under the page's directory,
+// in the same language, without the page itself, in the
+// order of its sort front matter field if any.
+func (p Page) children(pages Pages) Pages {
+	dir := filepath.Dir(p.RelPath)
+	children := make(Pages, 0)
+	for _, other := range pages {
+		if other.AbsPath != p.AbsPath && other.Lang == p.Lang &&
+			(dir == "." || isWithin(other.RelPath, dir)) {
+			children = append(children, other)
+		}
+	}
+	if order, ok := p.sortConfig(); ok {
+		sortPages(children, order)
+	}
+	return children
+}
+
+// isNotFound reports whether the page is the site's 404 page.
+func (p Page) isNotFound() bool {
+	return p.filename() == "404" && filepath.Dir(p.RelPath) == "."
+}
+
+// filename returns the file name of the page without
+// the extension and the language code.
+func (p Page) filename() string {
+	name := strings.TrimSuffix(filepath.Base(p.RelPath), filepath.Ext(p.RelPath))
+	if p.Lang != "" {
+		name = strings.TrimSuffix(name, "."+p.Lang)
+	}
+	return name
+}
+
+// outPath returns the path of the file the page is written to.
+func (p Page) outPath(outDir string) string {
+	path := filepath.Join(outDir, filepath.FromSlash(p.Url))
+	if p.Url == "" || strings.HasSuffix(p.Url, "/") {
+		path = filepath.Join(path, "index.html")
+	}
+	return path
+}
+
+// metaList converts a front matter value holding either a list
+// or a comma-separated string into a list of strings.
+func metaList(value interface{}) []string {
+	var list []string
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			list = append(list, fmt.Sprint(item))
+		}
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// date returns the page's front matter date.
+func (p Page) date() (time.Time, error) {
+	return p.metaDate("date")
+}
+
+// metaDate parses a date front matter field.
+func (p Page) metaDate(key string) (time.Time, error) {
+	dates := p.dateConfig()
+	switch v := p.Meta[key].(type) {
+	case time.Time:
+		// TOML dates without an offset are in the zone of the machine,
+		// read them in the site's as the other dates without one
+		if zone, _ := v.Zone(); zone == "date-local" || zone == "datetime-local" {
+			v = time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), v.Second(), v.Nanosecond(), dates.location)
+		}
+		return v.In(dates.location), nil
+	case string:
+		t, err := dates.parse(v)
+		return t.In(dates.location), err
+	case nil:
+		return time.Time{}, fmt.Errorf("no %s", key)
+	default:
+		return time.Time{}, fmt.Errorf("unrecognized date: %v", v)
+	}
+}
+
+// dateConfig returns the dates of the page's site,
+// or the defaults if it has none.
+func (p Page) dateConfig() *dateConfig {
+	if p.dates == nil {
+		return defaultDates
+	}
+	return p.dates
+}
+
+// dateFormats are the date formats of the dateformat template
+// function by name, along with those of the dateFormats config.
+var dateFormats = map[string]string{
+	"rfc822":     time.RFC822,
+	"yyyy-mm-dd": "2006-01-02",
+	"shortdate":  "02 Jan 2006",
+}
+
+// dateConfig holds the timezone of a site, dates without an offset
+// being in it and all shown in it, and its date formats by name.
+type dateConfig struct {
+	location *time.Location
+	formats  map[string]string
+}
+
+// defaultDates are the dates of the pages read without a site.
+var defaultDates = &dateConfig{location: time.UTC, formats: dateFormats}
+
+func newDateConfig(cfg Config) *dateConfig {
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		// checked when reading the config
+		loc = time.UTC
+	}
+	d := &dateConfig{location: loc, formats: make(map[string]string)}
+	for name, layout := range dateFormats {
+		d.formats[name] = layout
+	}
+	for name, layout := range cfg.DateFormats {
+		d.formats[name] = layout
+	}
+	return d
+}
+
+// funcs are available to all templates, along with those reading
+// the site's config and files (see templateFuncs). Functions taking
+// a string have it as the last argument to allow pipelines,
+// e.g. {{ .Page.Meta.title | truncate 20 }}.
+var funcs = template.FuncMap{
+	"slugify":  slugify,
+	"truncate": truncate,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+	"title":    title,
+	"plainify": func(s interface{}) string { return plainify(fmt.Sprint(s)) },
+	"safeHTML": func(s string) template.HTML { return template.HTML(s) },
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+	"trimPrefix": func(prefix, s string) string {
+		return strings.TrimPrefix(s, prefix)
+	},
+	"trimSuffix": func(suffix, s string) string {
+		return strings.TrimSuffix(s, suffix)
+	},
+	"where":   where,
+	"under":   under,
+	"sortBy":  sortBy,
+	"groupBy": groupBy,
+	"limit":   limit,
+}
+
+// templateFuncs returns the functions of the site's templates: funcs,
+// which has those of the plugins too, and the ones of the site.
+func (s *Site) templateFuncs() template.FuncMap {
+	fm := template.FuncMap{
+		"markdownify":    s.markdownify,
+		"renderMarkdown": s.renderMarkdown,
+		"readFile":       s.readFile,
+		"inlineCSS":      s.inlineCSS,
+		"inlineJS":       s.inlineJS,
+		"absURL":         s.absURL,
+		"fingerprint":    s.fingerprint,
+		"relURL":         s.relURL,
+		"srcset":         s.srcset,
+		"sources":        s.sources,
+		"dateformat":     s.dateformat,
+	}
+	for name, fn := range funcs {
+		fm[name] = fn
+	}
+	return fm
+}
+
+// dateformat formats a date in one of the date formats,
+// e.g. {{ dateformat "yyyy-mm-dd" "shortdate" .Page.Meta.date }}.
+func (s *Site) dateformat(src, dst string, input interface{}) (string, error) {
+	srcfmt, ok := s.dates.formats[src]
+	if !ok {
+		return "", fmt.Errorf("unknown date format: %s", src)
+	}
+
+	dstfmt, ok := s.dates.formats[dst]
+	if !ok {
+		return "", fmt.Errorf("unknown date format: %s", dst)
+	}
+	// YAML front matter already yields time.Time for dates,
+	// as does .Page.Date
+	if t, ok := input.(time.Time); ok {
+		return t.Format(dstfmt), nil
+	}
+	t, err := time.Parse(srcfmt, fmt.Sprint(input))
+	if err != nil {
+		return "", fmt.Errorf("%q is not a %s date", input, src)
+	}
+	return t.Format(dstfmt), nil
+}
+
+// parse parses a front matter date in any of the known formats,
+// in the site's timezone unless it has an offset.
+func (d *dateConfig) parse(value string) (time.Time, error) {
+	layouts := []string{
+		time.RFC3339, "2006-01-02T15:04:05-0700", "2006-01-02 15:04:05 -0700",
+		"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04",
+	}
+	names := make([]string, 0, len(d.formats))
+	for name := range d.formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		layouts = append(layouts, d.formats[name])
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, d.location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date: %q", value)
+}
+
+// readMeta splits off the front matter block, which is either
+// YAML delimited by "---" lines or TOML delimited by "+++" lines.
+// A "---" block that isn't a YAML mapping, such as a thematic
+// break starting the page, is left in the text. YAML dates without
+// an offset are read in the timezone of dates, if not nil.
+func readMeta(b []byte, dates *dateConfig) (map[string]interface{}, []byte, error) {
+	delim, block, rest, ok := splitMeta(b)
+	if !ok {
+		return nil, b, nil
+	}
+
+	meta := make(map[string]interface{})
+	if delim == "+++" {
+		if err := toml.Unmarshal(block, &meta); err != nil {
+			return nil, b, err
+		}
+		return meta, rest, nil
+	}
+	var v interface{}
+	if err := yaml.Unmarshal(block, &v); err != nil {
+		if frontMatterKey.Match(block) {
+			return nil, b, err
+		}
+		return nil, b, nil
+	}
+	switch v := v.(type) {
+	case nil:
+		// an empty block
+	case map[string]interface{}:
+		meta = v
+	default:
+		return nil, b, nil
+	}
+	if dates != nil && dates.location != time.UTC {
+		localYAMLDates(block, meta, dates)
+	}
+	return meta, rest, nil
+}
+
+// frontMatterKey matches a YAML block whose first line is a key,
+// to tell front matter with a syntax error from other text.
+var frontMatterKey = regexp.MustCompile(`^\s*[\w-]+\s*:`)
+
+// splitMeta splits b into the delimiter of its front matter, the
+// block and the text after the closing delimiter, both delimiters
+// being alone on their line, if b starts with one.
+func splitMeta(b []byte) (string, []byte, []byte, bool) {
+	line, rest, _ := bytes.Cut(b, []byte("\n"))
+	delim := string(bytes.TrimRight(line, " \t\r"))
+	if delim != "---" && delim != "+++" {
+		return "", nil, nil, false
+	}
+	start := len(b) - len(rest)
+	for i := start; i < len(b); {
+		end := bytes.IndexByte(b[i:], '\n')
+		if end == -1 {
+			end = len(b)
+		} else {
+			end += i
+		}
+		if string(bytes.TrimRight(b[i:end], " \t\r")) == delim {
+			// the text keeps the newline ending the closing delimiter
+			return delim, b[start:i], b[i+len(delim):], true
+		}
+		i = end + 1
+	}
+	return "", nil, nil, false
+}
+
+// localYAMLDates reads the YAML timestamps of the front matter
+// again, as YAML reads those without an offset in UTC rather
+// than in the site's timezone.
+func localYAMLDates(b []byte, meta map[string]interface{}, dates *dateConfig) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil || len(doc.Content) == 0 {
+		return
+	}
+	fields := doc.Content[0].Content
+	for i := 0; i+1 < len(fields); i += 2 {
+		key, value := fields[i], fields[i+1]
+		if value.ShortTag() != "!!timestamp" {
+			continue
+		}
+		if t, err := dates.parse(value.Value); err == nil {
+			meta[key.Value] = t
+		}
+	}
+}
+
+type Pages []Page
+
+func (p Pages) Len() int { return len(p) }
+func (p Pages) Less(i, j int) bool {
+	di, _ := p[i].date()
+	dj, _ := p[j].date()
+	if !di.Equal(dj) {
+		return di.After(dj)
+	}
+	return p[i].RelPath < p[j].RelPath
+}
+func (p Pages) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+// readPage reads the page at abspath, at relpath in the site.
+func (s *Site) readPage(abspath, relpath string) (Page, error) {
+	text, err := s.readSource(abspath)
+	if err != nil {
+		return Page{RelPath: relpath}, fmt.Errorf("failed to read page: %s", err)
+	}
+	meta, text, err := readMeta(text, s.dates)
+	if err != nil {
+		return Page{RelPath: relpath}, fmt.Errorf("failed to parse front matter: %s", err)
+	}
+	if r, ok := s.pageRenderer(filepath.Ext(abspath)); ok && meta == nil && r.Meta != nil {
+		if meta, text, err = r.Meta(text); err != nil {
+			return Page{RelPath: relpath}, fmt.Errorf("failed to read metadata: %s", err)
+		}
+	}
+
+	section := ""
+	if parts := strings.SplitN(filepath.ToSlash(relpath), "/", 2); len(parts) == 2 {
+		section = parts[0]
+	}
+
+	page := Page{
+		Meta:    meta,
+		Tags:    metaList(meta["tags"]),
+		AbsPath: abspath,
+		RelPath: relpath,
+		Section: section,
+		Text:    text,
+		dates:   s.dates,
+	}
+	return page, nil
+}
+
+// content returns the HTML of the page, or its summary
+// in low-memory builds, which don't keep the HTML.
+func (p Page) content() template.HTML {
+	if p.HTML == "" {
+		return p.Summary
+	}
+	return p.HTML
+}
+
+// readText reads the text of the page again, for builds
+// keeping it in memory only while it is rendered.
+func (s *Site) readText(page *Page, cfg Config) error {
+	fresh, err := s.readPage(page.AbsPath, page.RelPath)
+	if err != nil {
+		return err
+	}
+	applyCompat(&fresh, cfg)
+	page.Text = fresh.Text
+	return nil
+}
+
+// OutputDir returns the output directory of the site in siteDir:
+// outDir unless "", or else the one in the config.
+func OutputDir(siteDir, outDir string, cfg Config) string {
+	if outDir != "" {
+		return outDir
+	}
+	if filepath.IsAbs(cfg.Output) {
+		return cfg.Output
+	}
+	return filepath.Join(siteDir, cfg.Output)
+}
+
+// contentRoot is a directory the pages and static files of
+// the site are read from, as if they were in the directory mount.
+type contentRoot struct {
+	dir   string
+	mount string
+}
+
+// contentRoots returns the directories the pages and static files
+// of the site are read from: the site's, or Hugo's content/ and
+// static/ with compat hugo, the contentDirs of the config, relative
+// to it unless absolute, and the remote sources, in order of precedence.
+func (s *Site) contentRoots(siteDir string, cfg Config) []contentRoot {
+	roots := []contentRoot{{dir: siteDir}}
+	if cfg.Compat == Hugo {
+		roots = compatRoots(siteDir, cfg)
+	}
+	for _, dir := range cfg.ContentDirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(siteDir, dir)
+		}
+		roots = append(roots, contentRoot{dir: dir})
+	}
+	return append(roots, s.fetchRemote(siteDir, cfg)...)
+}
+
+// isContentRoot reports whether the directory is one of the content
+// directories, walked on its own when in another.
+func isContentRoot(path string, roots []contentRoot) bool {
+	for _, root := range roots {
+		if filepath.Clean(path) == filepath.Clean(root.dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// isIgnoredDir reports whether the directory should be skipped
+// when walking the site: archetypes, data, themes and the output itself.
+func (s *Site) isIgnoredDir(path, siteDir, outDir string) bool {
+	switch filepath.Clean(path) {
+	case filepath.Join(siteDir, archetypeDir),
+		filepath.Join(siteDir, dataDir),
+		filepath.Join(siteDir, jekyllDataDir),
+		filepath.Join(siteDir, "theme"),
+		filepath.Join(siteDir, "themes"):
+		return true
+	}
+	return s.outputInSource() && filepath.Clean(path) == filepath.Clean(outDir)
+}
+
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// isWithin reports whether path is dir or is located under it.
+func isWithin(path, dir string) bool {
+	path, dir = filepath.Clean(path), filepath.Clean(dir)
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// checkCollisions returns an error listing the output files
+// written by more than one page or static file.
+func checkCollisions(outDir string, pages Pages, assets map[string]string) error {
+	sources := make(map[string][]string)
+	for relpath := range assets {
+		outPath := filepath.Join(outDir, relpath)
+		sources[outPath] = append(sources[outPath], relpath)
+	}
+	for _, page := range pages {
+		outPath := page.outPath(outDir)
+		sources[outPath] = append(sources[outPath], page.RelPath)
+		for _, alias := range pageAliases(page) {
+			outPath := aliasPath(outDir, alias)
+			sources[outPath] = append(sources[outPath], page.RelPath+" (alias "+alias+")")
+		}
+	}
+
+	var conflicts []string
+	for outPath, relpaths := range sources {
+		if len(relpaths) > 1 {
+			sort.Strings(relpaths)
+			conflicts = append(conflicts, fmt.Sprintf("%s is written by %s", outPath, strings.Join(relpaths, ", ")))
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	sort.Strings(conflicts)
+	return fmt.Errorf("output collisions:\n  %s", strings.Join(conflicts, "\n  "))
+}
+
+func (s *Site) build(siteDir, outDir string, cfg Config) {
+	start := time.Now()
+	s.stats.reset()
+	s.outputs.reset()
+	s.written.reset()
+	s.runHooks("before", cfg.Hooks.Before, siteDir, outDir, cfg)
+	theme := s.themeDir(siteDir, cfg)
+	tmplDirs := []string{siteDir}
+	if theme != "" {
+		tmplDirs = append(tmplDirs, theme)
+	}
+	partials := s.readPartials(tmplDirs)
+	baseTmpl := s.readTmpl(tmplDirs, partials, "base.tmpl", defaultTmpl)
+	taxonomyTmpl := s.readTmpl(tmplDirs, partials, "taxonomy.tmpl", defaultTaxonomyTmpl)
+	archiveTmpl := s.readTmpl(tmplDirs, partials, "archive.tmpl", defaultArchiveTmpl)
+	authorsTmpl := s.readTmpl(tmplDirs, partials, "authors.tmpl", defaultAuthorsTmpl)
+	seriesTmpl := s.readTmpl(tmplDirs, partials, "series.tmpl", defaultSeriesTmpl)
+	listTmpl := s.readTmpl(tmplDirs, partials, "list.tmpl", nil)
+	notFoundTmpl := s.readTmpl(tmplDirs, partials, "404.tmpl", nil)
+	shortcodes, err := s.readShortcodes(tmplDirs, partials)
+	if err != nil {
+		fatal("failed to parse shortcodes:", err)
+	}
+
+	// pages failing to build are left out and reported at the end
+	var errs pageErrors
+	now := time.Now()
+	if epoch, ok := sourceDate(); ok {
+		now = epoch
+	}
+	pages := make(Pages, 0)
+	// the site's files take precedence over the theme's
+	assets, deps := s.readTheme(theme)
+	data, dataFiles, err := s.readData(tmplDirs)
+	if err != nil {
+		fatal("failed to read data:", err)
+	}
+	ignored := s.readIgnoreRules(siteDir, cfg)
+	included := parseIgnoreRules(append(compatIncludes(cfg), cfg.Include...))
+	// the content directories are merged into the site, the first
+	// having a page or file taking precedence
+	pagePaths := make(map[string]string)
+	assetRoots := make(map[string]string)
+	roots := s.contentRoots(siteDir, cfg)
+	// the output of the config is left out too when building elsewhere,
+	// as the variants of a site in a workspace do
+	cfgOutDir := OutputDir(siteDir, "", cfg)
+	for _, root := range roots {
+		err = s.walkSource(root.dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == root.dir {
+				return nil
+			}
+			relpath, err := filepath.Rel(root.dir, path)
+			if err != nil {
+				return err
+			}
+			relpath = filepath.Join(root.mount, relpath)
+			skip := ignored.match(relpath, d.IsDir()) ||
+				s.isSkipped(d.Name()) && !included.match(relpath, d.IsDir())
+			if d.IsDir() {
+				if skip || s.isIgnoredDir(path, siteDir, outDir) || s.isIgnoredDir(path, siteDir, cfgOutDir) || isContentRoot(path, roots) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if skip {
+				return nil
+			}
+			if !s.isPage(path) {
+				if isStatic(path) {
+					name := assetName(relpath)
+					// site files override the theme's but not each other
+					if other, ok := assets[name]; ok {
+						if assetRoots[name] == root.dir {
+							return fmt.Errorf("%s is written by %s and %s", name, other, path)
+						}
+						if assetRoots[name] != "" {
+							s.logDebug("skipping %s: overridden by %s", path, other)
+							return nil
+						}
+					}
+					assets[name] = path
+					assetRoots[name] = root.dir
+				} else {
+					deps = append(deps, path)
+				}
+				return nil
+			}
+			if other, ok := pagePaths[relpath]; ok {
+				s.logDebug("skipping %s: overridden by %s", path, other)
+				return nil
+			}
+			pagePaths[relpath] = path
+			page, err := s.readPage(path, relpath)
+			if err != nil {
+				errs.add(page.RelPath, err)
+				return nil
+			}
+			applyCompat(&page, cfg)
+			page.Lang = pageLang(page.RelPath, cfg)
+			if cfg.LowMemory {
+				page.Text = nil
+			}
+			pages = append(pages, page)
+			return nil
+		})
+		if err != nil {
+			fatal("failed to read site:", err)
+		}
+	}
+
+	applyCascades(pages)
+	for i := range pages {
+		if _, ok := pages[i].Meta["date"]; !ok {
+			continue
+		}
+		date, err := pages[i].date()
+		if err != nil {
+			errs.add(pages[i].RelPath, err)
+			continue
+		}
+		pages[i].Date = date
+	}
+	s.setLastMod(pages, roots, cfg)
+	setAuthors(pages, cfg, data)
+	published := pages[:0]
+	for _, page := range pages {
+		if page.Meta["draft"] == true && !cfg.Drafts {
+			continue
+		}
+		if date, err := page.date(); err == nil && date.After(now) && !cfg.Future {
+			continue
+		}
+		if date, err := page.metaDate("expiryDate"); err == nil && !date.After(now) && !cfg.Expired {
+			continue
+		}
+		url, err := pageURL(page, cfg)
+		if err != nil {
+			errs.add(page.RelPath, fmt.Errorf("failed to build url: %s", err))
+			continue
+		}
+		page.Url = url
+		if cfg.SocialCards.Enabled {
+			page.Card = cardURL(page.Url)
+		}
+		published = append(published, page)
+	}
+	pages = published
+	s.validatePages(pages, cfg)
+	sortPages(pages, cfg.Sort)
+	setResources(pages, assets)
+	if err := checkCollisions(outDir, pages, assets); err != nil {
+		fatal(err)
+	}
+	s.stats.add(&s.stats.Read, "", start)
+
+	// pages use the template chosen in the front matter, or else
+	// the nearest _layout.tmpl of their section, or else base.tmpl
+	layouts := make(map[string]*template.Template)
+	pageTmpls := make([]*template.Template, len(pages))
+	for i, page := range pages {
+		pageTmpls[i] = baseTmpl
+		if page.isNotFound() && notFoundTmpl != nil && page.layout() == "" {
+			pageTmpls[i] = notFoundTmpl
+		} else if page.isSectionList() && listTmpl != nil && page.layout() == "" {
+			pageTmpls[i] = listTmpl
+		} else if name := page.layout(); name != "" {
+			if layouts[name] == nil {
+				layouts[name] = s.readTmpl(tmplDirs, partials, name, nil)
+			}
+			// the other generator's layouts are not marc's templates
+			if layouts[name] == nil && cfg.Compat != "" {
+				continue
+			}
+			if layouts[name] == nil {
+				errs.add(page.RelPath, fmt.Errorf("layout %s not found", name))
+			}
+			pageTmpls[i] = layouts[name]
+		} else if name := s.sectionLayout(siteDir, page.RelPath); name != "" {
+			if layouts[name] == nil {
+				layouts[name] = s.readTmpl([]string{siteDir}, partials, name, nil)
+			}
+			pageTmpls[i] = layouts[name]
+		}
+	}
+
+	s.fingerprints.reset(s, outDir, assets)
+	s.includes.reset(s, assets)
+	s.images.reset(s, outDir, assets, cfg.Images)
+	// templates and data files only rebuild the pages using them
+	siteDeps := make([]string, 0, len(deps))
+	for _, path := range deps {
+		if filepath.Ext(path) != ".tmpl" {
+			siteDeps = append(siteDeps, path)
+		}
+	}
+	// but the authors of all pages are read from data/authors
+	for _, path := range dataFiles {
+		if dataKey(tmplDirs, path) == "authors" {
+			siteDeps = append(siteDeps, path)
+		}
+	}
+	cache := s.newBuildCache(outDir, cfg, siteDeps, pages)
+	tmplDeps := s.newTmplDeps(tmplDirs, partials, dataFiles)
+	tmplHashes := make([]string, len(pages))
+	for i := range pages {
+		tmplHashes[i] = tmplDeps.hash(pageTmpls[i])
+	}
+
+	relpaths := make([]string, 0, len(assets))
+	for relpath := range assets {
+		relpaths = append(relpaths, relpath)
+	}
+	sort.Strings(relpaths)
+	for _, relpath := range relpaths {
+		path := assets[relpath]
+		outPath := filepath.Join(outDir, relpath)
+		content, err := s.readAsset(path)
+		if err != nil {
+			fatal("failed to read file:", err)
+		}
+		if cache.unchanged(relpath, hashBytes(content), outPath) {
+			continue
+		}
+		if s.canMinify(outPath) || s.canCompress(outPath) || isSass(path) || s.dryRun {
+			s.writeFile(outPath, content)
+			continue
+		}
+		s.logOutput(outPath)
+		t := time.Now()
+		if err := s.writeOutput(outPath, content); err != nil {
+			fatalIO("failed to write file:", err)
+		}
+		s.stats.add(&s.stats.Write, "", t)
+	}
+
+	urls := pageURLs(pages)
+	targets := wikiTargets(pages)
+	// resolveLinks points the links of a page to the other pages
+	// and its images to their processed versions
+	resolveLinks := func(doc ast.Node, page *Page) error {
+		if err := s.processImages(doc, page); err != nil {
+			return fmt.Errorf("failed to process images: %s", err)
+		}
+		s.rewriteLinks(doc, page, urls)
+		if err := s.resolveWikiLinks(doc, page, targets, cfg.Markdown); err != nil {
+			return fmt.Errorf("failed to resolve links: %s", err)
+		}
+		page.links = s.pageLinks(doc, page)
+		return nil
+	}
+	convert := func(md goldmark.Markdown, buf *bytes.Buffer, page *Page) error {
+		return s.convertPage(md, buf, page, shortcodes, cfg, resolveLinks)
+	}
+	parallel(len(pages), func() func(int) {
+		md := s.newMarkdown(cfg.Markdown)
+		var buf bytes.Buffer
+		return func(i int) {
+			defer s.stats.add(&s.stats.Markdown, pages[i].RelPath, time.Now())
+			if cfg.LowMemory {
+				if err := s.readText(&pages[i], cfg); err != nil {
+					errs.add(pages[i].RelPath, err)
+					return
+				}
+			}
+			if err := convert(md, &buf, &pages[i]); err != nil {
+				errs.add(pages[i].RelPath, err)
+				return
+			}
+			pages[i].textHash = hashBytes(pages[i].Text)
+			// only the summaries are kept, the text and HTML
+			// being read and rendered again for the page's own output
+			if cfg.LowMemory {
+				pages[i].Text = nil
+				pages[i].HTML = ""
+			}
+		}
+	})
+
+	linkPages(pages)
+	linkTranslations(pages)
+	series := linkSeries(pages, cfg)
+	collectBacklinks(pages)
+	setBreadcrumbs(pages, cfg)
+	relatePages(pages, cfg.Related)
+	tags := s.collectTags(pages)
+	// list pages only see the pages of their language
+	langPages := make(map[string]Pages)
+	for _, page := range pages {
+		langPages[page.Lang] = append(langPages[page.Lang], page)
+	}
+	// pages failing to render are built again next time
+	fail := func(page Page, err error) {
+		errs.add(page.RelPath, err)
+		cache.forget(page.RelPath)
+	}
+	parallel(len(pages), func() func(int) {
+		var buf, mdBuf bytes.Buffer
+		var md goldmark.Markdown
+		if cfg.LowMemory {
+			md = s.newMarkdown(cfg.Markdown)
+		}
+		var cards *cardRenderer
+		if cfg.SocialCards.Enabled {
+			var err error
+			if cards, err = s.newCardRenderer(siteDir, cfg.SocialCards); err != nil {
+				fatal("failed to set up social cards:", err)
+			}
+		}
+		return func(i int) {
+			page := pages[i]
+			tmpl := pageTmpls[i]
+			defer s.stats.add(nil, page.RelPath, time.Now())
+			if errs.failed(page.RelPath) {
+				return
+			}
+			outPath := page.outPath(outDir)
+			data := map[string]interface{}{
+				"Page":  page,
+				"Pages": langPages[page.Lang],
+				"Tags":  tags,
+				"Site":  cfg,
+				"Data":  data,
+			}
+			if page.Lang != "" {
+				data["Lang"] = cfg.language(page.Lang)
+			}
+			if page.isSectionList() {
+				data["Pages"] = page.children(pages)
+			}
+
+			// list pages are always rendered as their content
+			// depends on the other pages, and the others when
+			// the pages they link to or that link to them, or
+			// their templates change
+			hash := hashBytes([]byte(page.textHash), []byte(linkedHash(page)), []byte(page.LastMod.String()), []byte(tmplHashes[i]),
+				[]byte(fmt.Sprint(page.Resources)))
+			if cache.unchanged(page.RelPath, hash, outPath) && !page.isIndex() {
+				if page.Card != "" {
+					s.outputs.add(filepath.Join(outDir, filepath.FromSlash(page.Card)))
+				}
+				for _, format := range page.formats(cfg) {
+					s.keepOutput(formatPath(outPath, format))
+				}
+				return
+			}
+			if cfg.LowMemory {
+				err := s.readText(&page, cfg)
+				if err == nil {
+					err = convert(md, &mdBuf, &page)
+				}
+				if err != nil {
+					fail(page, err)
+					return
+				}
+				data["Page"] = page
+			}
+
+			if cards != nil {
+				title := page.metaString("title")
+				if title == "" {
+					title = cfg.Title
+				}
+				card, err := cards.render(title, cfg.Title)
+				if err != nil {
+					fail(page, fmt.Errorf("failed to render social card: %s", err))
+					return
+				}
+				s.writeFile(filepath.Join(outDir, filepath.FromSlash(page.Card)), card)
+			}
+			if formats := page.formats(cfg); len(formats) > 0 {
+				if err := s.writePageFormats(&page, outPath, formats); err != nil {
+					fail(page, err)
+					return
+				}
+			}
+
+			// the home page and section lists list their pages in chunks
+			if (page.isSectionList() || page.isIndex() && filepath.Dir(page.RelPath) == ".") && cfg.Paginate > 0 {
+				for i, pager := range paginate(page.children(pages), cfg.Paginate, page.Url) {
+					if i > 0 {
+						outPath = filepath.Join(outDir, filepath.FromSlash(pagerURL(page.Url, i+1)), "index.html")
+					}
+					data["Paginator"] = pager
+					body, err := s.execute(&buf, tmpl, data)
+					if err == nil {
+						body, err = filterHTML(&page, body)
+					}
+					if err != nil {
+						fail(page, err)
+						return
+					}
+					s.writeFile(outPath, body)
+				}
+				return
+			}
+
+			body, err := s.execute(&buf, tmpl, data)
+			if err == nil {
+				body, err = filterHTML(&page, body)
+			}
+			if err != nil {
+				fail(page, err)
+				return
+			}
+			s.writeFile(outPath, body)
+		}
+	})
+
+	s.writeTaxonomy(outDir, cfg, taxonomyTmpl, pages, tags, data)
+	s.writeArchive(outDir, cfg, archiveTmpl, pages, tags, data)
+	s.writeAuthors(outDir, cfg, authorsTmpl, pages, tags, data)
+	s.writeSeries(outDir, cfg, seriesTmpl, series, pages, tags, data)
+	s.writeNotFound(outDir, cfg, notFoundTmpl, pages, tags, data)
+	s.writeAliases(outDir, cfg, pages)
+	s.writeHostingFiles(outDir, cfg, pages, assets)
+	var reconvert func(page *Page) error
+	if cfg.LowMemory {
+		md := s.newMarkdown(cfg.Markdown)
+		var buf bytes.Buffer
+		reconvert = func(page *Page) error {
+			if err := s.readText(page, cfg); err != nil {
+				return err
+			}
+			return convert(md, &buf, page)
+		}
+	}
+	s.writeFeeds(outDir, cfg, pages, tags, reconvert)
+	s.writeSitemap(outDir, cfg, pages)
+	s.writeSearch(outDir, cfg, pages)
+	s.writeDeployFiles(outDir, cfg, assets)
+	s.generatePlugins(outDir, cfg, pages)
+	cache.keepFingerprints(outDir)
+	// a dry run leaves the output, and so its links, as they were
+	if !s.dryRun {
+		cache.write(outDir)
+		if err := s.reportBrokenLinks(outDir, cfg, pages); err != nil {
+			fatal(err)
+		}
+	}
+	errs.report(s)
+	// only builds without errors prune, leaving pages failing
+	// to build their previous output
+	if cfg.Clean {
+		if s.srcOnDisk && isWithin(siteDir, outDir) {
+			fatalf("refusing to prune %s: it contains the site", outDir)
+		}
+		s.prune(outDir, s.outputs.has)
+	}
+	s.runHooks("after", cfg.Hooks.After, siteDir, outDir, cfg)
+	s.stats.done(len(pages), len(assets), len(tags), time.Since(start))
+	s.logStats()
+	if cfg.Stats != "" {
+		s.stats.write(cfg.Stats)
+	}
+}
+
+// parallel calls the function returned by newWorker for each index
+// in [0, n), spread over GOMAXPROCS goroutines. newWorker is called
+// once per goroutine to set up its state. If a call panics, the rest
+// are skipped and parallel panics with the same value once all done.
+func parallel(n int, newWorker func() func(i int)) {
+	workers := make([]func(int), runtime.GOMAXPROCS(0))
+	for w := range workers {
+		workers[w] = newWorker()
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failure interface{}
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return failure != nil
+	}
+	for _, work := range workers {
+		wg.Add(1)
+		go func(work func(int)) {
+			defer wg.Done()
+			for i := range jobs {
+				if failed() {
+					continue
+				}
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							mu.Lock()
+							if failure == nil {
+								failure = r
+							}
+							mu.Unlock()
+						}
+					}()
+					work(i)
+				}()
+			}
+		}(work)
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if failure != nil {
+		panic(failure)
+	}
+}
+
+func (s *Site) render(buf *bytes.Buffer, tmpl *template.Template, data map[string]interface{}) []byte {
+	body, err := s.execute(buf, tmpl, data)
+	if err != nil {
+		fatal(err)
+	}
+	return body
+}
+
+func (s *Site) execute(buf *bytes.Buffer, tmpl *template.Template, data map[string]interface{}) ([]byte, error) {
+	defer s.stats.add(&s.stats.Templates, "", time.Now())
+	buf.Reset()
+	if err := tmpl.Execute(buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render page: %s", s.locateTmplError(err))
+	}
+	return buf.Bytes(), nil
+}
+
+// writeNotFound renders 404.tmpl to 404.html
+// for sites without a 404.md.
+func (s *Site) writeNotFound(outDir string, cfg Config, tmpl *template.Template, pages Pages, tags map[string]Pages, data map[string]interface{}) {
+	if tmpl == nil {
+		return
+	}
+	for _, page := range pages {
+		if page.isNotFound() {
+			return
+		}
+	}
+	var buf bytes.Buffer
+	page := Page{
+		Meta: map[string]interface{}{"title": "Page not found"},
+		Url:  "404.html",
+	}
+	s.writeFile(filepath.Join(outDir, "404.html"), s.render(&buf, tmpl, map[string]interface{}{
+		"Page":  page,
+		"Pages": pages,
+		"Tags":  tags,
+		"Site":  cfg,
+		"Data":  data,
+	}))
+}
+
+func (s *Site) writeFile(path string, body []byte) {
+	defer s.stats.add(&s.stats.Write, "", time.Now())
+	body = s.minifyFile(path, body)
+	if s.dryRun {
+		s.wouldWrite(path, body)
+		return
+	}
+	s.logOutput(path)
+	if err := s.writeOutput(path, body); err != nil {
+		fatalIO("failed to write file:", err)
+	}
+	s.writeCompressed(path, body)
+}
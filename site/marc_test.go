@@ -0,0 +1,83 @@
+package site
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadMeta(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		meta map[string]interface{}
+		text string
+		err  bool
+	}{
+		{name: "empty", in: "", text: ""},
+		{name: "no front matter", in: "# Title\n", text: "# Title\n"},
+		{
+			name: "yaml",
+			in:   "---\ntitle: Hello\ntags: [a, b]\n---\n# Body\n",
+			meta: map[string]interface{}{"title": "Hello", "tags": []interface{}{"a", "b"}},
+			text: "\n# Body\n",
+		},
+		{
+			name: "toml",
+			in:   "+++\ntitle = \"Hello\"\ndraft = true\n+++\nBody",
+			meta: map[string]interface{}{"title": "Hello", "draft": true},
+			text: "\nBody",
+		},
+		{
+			name: "crlf and trailing spaces",
+			in:   "--- \r\ntitle: Hello\r\n---\r\nBody",
+			meta: map[string]interface{}{"title": "Hello"},
+			text: "\r\nBody",
+		},
+		{
+			name: "yaml dates",
+			in:   "---\ndate: 2024-03-05\n---\n",
+			meta: map[string]interface{}{"date": time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+			text: "\n",
+		},
+		{name: "empty block", in: "---\n---\nBody", meta: map[string]interface{}{}, text: "\nBody"},
+		{name: "closing delimiter at the end", in: "---\ntitle: Hello\n---", meta: map[string]interface{}{"title": "Hello"}, text: ""},
+		{
+			name: "dashes inside a value",
+			in:   "---\ntitle: a---b\n---\nBody",
+			meta: map[string]interface{}{"title": "a---b"},
+			text: "\nBody",
+		},
+		{
+			name: "thematic break",
+			in:   "---\n\nSome text.\n\n---\n\nMore text.\n",
+			text: "---\n\nSome text.\n\n---\n\nMore text.\n",
+		},
+		{name: "thematic break alone", in: "---\n\nSome text.\n", text: "---\n\nSome text.\n"},
+		{name: "list between breaks", in: "---\n- a\n- b\n---\n", text: "---\n- a\n- b\n---\n"},
+		{name: "longer rule", in: "----\ntitle: x\n----\n", text: "----\ntitle: x\n----\n"},
+		{name: "delimiter not alone", in: "--- title: x\n---\n", text: "--- title: x\n---\n"},
+		{name: "closing delimiter not alone", in: "---\ntitle: x\n--- x\n", text: "---\ntitle: x\n--- x\n"},
+		{name: "yaml syntax error", in: "---\ntitle: [a\n---\n", err: true},
+		{name: "toml syntax error", in: "+++\ntitle = \n+++\n", err: true},
+	}
+	for _, test := range tests {
+		meta, text, err := readMeta([]byte(test.in), nil)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", test.name, meta)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(meta, test.meta) {
+			t.Errorf("%s: got meta %#v, want %#v", test.name, meta, test.meta)
+		}
+		if string(text) != test.text {
+			t.Errorf("%s: got text %q, want %q", test.name, text, test.text)
+		}
+	}
+}
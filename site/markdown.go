@@ -0,0 +1,104 @@
+package site
+
+import (
+	"github.com/yuin/goldmark"
+	emoji "github.com/yuin/goldmark-emoji"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// markdownExtensions are the goldmark extensions
+// that can be enabled in the markdown config.
+var markdownExtensions = map[string]goldmark.Extender{
+	"table":          extension.Table,
+	"strikethrough":  extension.Strikethrough,
+	"tasklist":       extension.TaskList,
+	"linkify":        extension.Linkify,
+	"footnote":       extension.Footnote,
+	"definitionlist": extension.DefinitionList,
+	"gfm":            extension.GFM,
+}
+
+func (s *Site) newMarkdown(cfg MarkdownConfig) goldmark.Markdown {
+	var parserOpts []parser.Option
+	if cfg.AutoHeadingID {
+		parserOpts = append(parserOpts, parser.WithAutoHeadingID())
+	}
+	if cfg.Attributes {
+		parserOpts = append(parserOpts, parser.WithAttribute())
+	}
+	if cfg.HeadingIDPrefix != "" {
+		parserOpts = append(parserOpts, parser.WithASTTransformers(
+			util.Prioritized(&headingIDTransformer{prefix: cfg.HeadingIDPrefix}, 100)))
+	}
+	var rendererOpts []renderer.Option
+	if cfg.Unsafe {
+		rendererOpts = append(rendererOpts, html.WithUnsafe())
+	}
+	if cfg.HardWraps {
+		rendererOpts = append(rendererOpts, html.WithHardWraps())
+	}
+	if cfg.XHTML {
+		rendererOpts = append(rendererOpts, html.WithXHTML())
+	}
+	var extensions []goldmark.Extender
+	for _, name := range cfg.Extensions {
+		ext, ok := markdownExtensions[name]
+		if !ok {
+			fatal("unknown markdown extension: ", name)
+		}
+		extensions = append(extensions, ext)
+	}
+	if cfg.Math {
+		extensions = append(extensions, &mathExtension{})
+	}
+	if cfg.Typographer {
+		extensions = append(extensions, extension.Typographer)
+	}
+	if cfg.Figures || cfg.LazyImages {
+		extensions = append(extensions, &figureExtension{figures: cfg.Figures, lazy: cfg.LazyImages})
+	}
+	if cfg.HeadingAnchor != "" {
+		extensions = append(extensions, &anchorExtension{symbol: cfg.HeadingAnchor})
+	}
+	if cfg.ExternalLinks.Enabled {
+		extensions = append(extensions, &externalLinkExtension{cfg: cfg.ExternalLinks, baseURL: s.cfg.BaseURL})
+	}
+	if cfg.Emoji {
+		extensions = append(extensions, emoji.New(emoji.WithRenderingMethod(emoji.Unicode)))
+	}
+	if cfg.Mermaid {
+		extensions = append(extensions, &mermaidExtension{})
+	}
+	if cfg.Wikilinks.Enabled {
+		extensions = append(extensions, &wikiLinkExtension{})
+	}
+	extensions = append(extensions, pluginExtensions()...)
+	return goldmark.New(
+		goldmark.WithExtensions(extensions...),
+		goldmark.WithParserOptions(parserOpts...),
+		goldmark.WithRendererOptions(rendererOpts...),
+	)
+}
+
+// headingIDTransformer puts a prefix before the ids of the headings.
+type headingIDTransformer struct {
+	prefix string
+}
+
+func (t *headingIDTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || n.Kind() != ast.KindHeading {
+			return ast.WalkContinue, nil
+		}
+		if id, ok := n.AttributeString("id"); ok {
+			n.SetAttributeString("id", append([]byte(t.prefix), id.([]byte)...))
+		}
+		return ast.WalkSkipChildren, nil
+	})
+}
@@ -0,0 +1,189 @@
+package site
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// The math extension keeps $...$ and $$...$$ away from the markdown
+// parser and renders them with the \(...\) and \[...\] delimiters
+// recognized by KaTeX's auto-render (and MathJax).
+
+var (
+	kindMath      = ast.NewNodeKind("Math")
+	kindMathBlock = ast.NewNodeKind("MathBlock")
+)
+
+type mathNode struct {
+	ast.BaseInline
+	Display bool
+	Value   text.Segment
+}
+
+func (n *mathNode) Kind() ast.NodeKind { return kindMath }
+
+func (n *mathNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type mathBlockNode struct {
+	ast.BaseBlock
+	closed bool
+}
+
+func (n *mathBlockNode) Kind() ast.NodeKind { return kindMathBlock }
+
+func (n *mathBlockNode) IsRaw() bool { return true }
+
+func (n *mathBlockNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type mathParser struct{}
+
+func (p *mathParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (p *mathParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	delim := 1
+	if len(line) > 1 && line[1] == '$' {
+		delim = 2
+	}
+	// like pandoc: no space after the opening or before the closing
+	// dollar, and no digit after the closing one, so "$5 and $10" is
+	// left alone
+	if len(line) <= delim || util.IsSpace(line[delim]) {
+		return nil
+	}
+	for i := delim; i < len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+		case line[i] == '$':
+			if !bytes.HasPrefix(line[i:], line[:delim]) || util.IsSpace(line[i-1]) {
+				continue
+			}
+			if delim == 1 && i+1 < len(line) && util.IsNumeric(line[i+1]) {
+				continue
+			}
+			node := &mathNode{
+				Display: delim == 2,
+				Value:   text.NewSegment(segment.Start+delim, segment.Start+i),
+			}
+			block.Advance(i + delim)
+			return node
+		}
+	}
+	return nil
+}
+
+type mathBlockParser struct{}
+
+func (p *mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (p *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+	node := &mathBlockNode{}
+	rest := line[pos+2:]
+	if i := bytes.Index(rest, []byte("$$")); i != -1 {
+		// $$ ... $$ on a single line
+		if !util.IsBlank(rest[i+2:]) {
+			return nil, parser.NoChildren
+		}
+		start := segment.Start + pos + 2
+		node.Lines().Append(text.NewSegment(start, start+i))
+		node.closed = true
+	} else if !util.IsBlank(rest) {
+		start := segment.Start + pos + 2
+		node.Lines().Append(text.NewSegment(start, segment.Stop))
+	}
+	return node, parser.NoChildren
+}
+
+func (p *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n := node.(*mathBlockNode)
+	if n.closed {
+		return parser.Close
+	}
+	line, segment := reader.PeekLine()
+	if i := bytes.Index(line, []byte("$$")); i != -1 {
+		if i > 0 {
+			n.Lines().Append(text.NewSegment(segment.Start, segment.Start+i))
+		}
+		reader.Advance(segment.Len() - 1)
+		return parser.Close
+	}
+	n.Lines().Append(segment)
+	reader.Advance(segment.Len() - 1)
+	return parser.Continue | parser.NoChildren
+}
+
+func (p *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (p *mathBlockParser) CanInterruptParagraph() bool { return true }
+
+func (p *mathBlockParser) CanAcceptIndentedLine() bool { return false }
+
+type mathRenderer struct{}
+
+func (r *mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMath, r.renderMath)
+	reg.Register(kindMathBlock, r.renderMathBlock)
+}
+
+func (r *mathRenderer) renderMath(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*mathNode)
+	if n.Display {
+		w.WriteString(`<span class="math display">\[`)
+		w.Write(util.EscapeHTML(n.Value.Value(source)))
+		w.WriteString(`\]</span>`)
+	} else {
+		w.WriteString(`<span class="math inline">\(`)
+		w.Write(util.EscapeHTML(n.Value.Value(source)))
+		w.WriteString(`\)</span>`)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *mathRenderer) renderMathBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	w.WriteString(`<div class="math display">\[`)
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		w.Write(util.EscapeHTML(segment.Value(source)))
+	}
+	w.WriteString("\\]</div>\n")
+	return ast.WalkSkipChildren, nil
+}
+
+type mathExtension struct{}
+
+func (e *mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(&mathBlockParser{}, 700)),
+		parser.WithInlineParsers(util.Prioritized(&mathParser{}, 500)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&mathRenderer{}, 500)),
+	)
+}
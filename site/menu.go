@@ -0,0 +1,70 @@
+package site
+
+import (
+	"sort"
+	"strings"
+)
+
+// MenuEntry is a link of a menu defined in the config.
+type MenuEntry struct {
+	Name   string `toml:"name" yaml:"name"`
+	URL    string `toml:"url" yaml:"url"`
+	Weight int    `toml:"weight" yaml:"weight"`
+	// Parent is the name of the entry this one is nested under.
+	Parent   string `toml:"parent" yaml:"parent"`
+	Children Menu   `toml:"-" yaml:"-"`
+}
+
+// Menu is a list of entries sorted by weight, then name.
+type Menu []*MenuEntry
+
+// tree nests the entries under their parents and sorts them.
+func (m Menu) tree() Menu {
+	byName := make(map[string]*MenuEntry, len(m))
+	for _, entry := range m {
+		entry.Children = nil
+		byName[entry.Name] = entry
+	}
+	var roots Menu
+	for _, entry := range m {
+		if parent := byName[entry.Parent]; parent != nil && parent != entry {
+			parent.Children = append(parent.Children, entry)
+		} else {
+			roots = append(roots, entry)
+		}
+	}
+	roots.sort()
+	return roots
+}
+
+func (m Menu) sort() {
+	sort.SliceStable(m, func(i, j int) bool {
+		if m[i].Weight != m[j].Weight {
+			return m[i].Weight < m[j].Weight
+		}
+		return m[i].Name < m[j].Name
+	})
+	for _, entry := range m {
+		entry.Children.sort()
+	}
+}
+
+// IsActive reports whether the entry links to the page at url,
+// e.g. {{ if .IsActive $.Page.Url }}.
+func (e *MenuEntry) IsActive(url string) bool {
+	return strings.TrimPrefix(e.URL, "/") == strings.TrimPrefix(url, "/")
+}
+
+// HasActive reports whether the page at url is one of the entry's
+// children or is under the entry's url, like posts/hello.html
+// under /posts/.
+func (e *MenuEntry) HasActive(url string) bool {
+	for _, child := range e.Children {
+		if child.IsActive(url) || child.HasActive(url) {
+			return true
+		}
+	}
+	prefix := strings.TrimPrefix(e.URL, "/")
+	url = strings.TrimPrefix(url, "/")
+	return strings.HasSuffix(prefix, "/") && prefix != url && strings.HasPrefix(url, prefix)
+}
@@ -0,0 +1,91 @@
+package site
+
+import (
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// The mermaid extension renders ```mermaid code blocks as
+// <pre class="mermaid"> elements, drawn by the mermaid script.
+
+var kindMermaid = ast.NewNodeKind("Mermaid")
+
+type mermaidNode struct {
+	ast.BaseBlock
+}
+
+func (n *mermaidNode) Kind() ast.NodeKind { return kindMermaid }
+
+func (n *mermaidNode) IsRaw() bool { return true }
+
+func (n *mermaidNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// mermaidTransformer replaces the mermaid code blocks with mermaid nodes.
+type mermaidTransformer struct{}
+
+func (t *mermaidTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	var blocks []*ast.FencedCodeBlock
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if block, ok := n.(*ast.FencedCodeBlock); ok && entering {
+			if string(block.Language(reader.Source())) == "mermaid" {
+				blocks = append(blocks, block)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, block := range blocks {
+		node := &mermaidNode{}
+		node.SetLines(block.Lines())
+		block.Parent().ReplaceChild(block.Parent(), block, node)
+	}
+}
+
+type mermaidRenderer struct{}
+
+func (r *mermaidRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMermaid, r.renderMermaid)
+}
+
+func (r *mermaidRenderer) renderMermaid(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	w.WriteString(`<pre class="mermaid">`)
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		w.Write(util.EscapeHTML(segment.Value(source)))
+	}
+	w.WriteString("</pre>\n")
+	return ast.WalkSkipChildren, nil
+}
+
+type mermaidExtension struct{}
+
+func (e *mermaidExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(util.Prioritized(&mermaidTransformer{}, 500)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&mermaidRenderer{}, 500)),
+	)
+}
+
+// hasMermaid reports whether a document has mermaid diagrams.
+func hasMermaid(doc ast.Node) bool {
+	found := false
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if n.Kind() == kindMermaid {
+			found = true
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return found
+}
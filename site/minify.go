@@ -0,0 +1,61 @@
+package site
+
+import (
+	"path/filepath"
+	"regexp"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+	"github.com/tdewolff/minify/v2/json"
+	"github.com/tdewolff/minify/v2/svg"
+	"github.com/tdewolff/minify/v2/xml"
+)
+
+// minifyTypes maps the extensions of the files that can be
+// minified to their media types.
+var minifyTypes = map[string]string{
+	".html": "text/html",
+	".css":  "text/css",
+	".js":   "application/javascript",
+	".json": "application/json",
+	".svg":  "image/svg+xml",
+	".xml":  "text/xml",
+}
+
+// newMinifier returns the minifier of the output files,
+// nil unless enabled.
+func newMinifier(enabled bool) *minify.M {
+	if !enabled {
+		return nil
+	}
+	m := minify.New()
+	m.AddFunc("text/html", html.Minify)
+	m.AddFunc("text/css", css.Minify)
+	m.AddFunc("image/svg+xml", svg.Minify)
+	m.AddFuncRegexp(regexp.MustCompile("^(application|text)/(x-)?(java|ecma)script$"), js.Minify)
+	m.AddFuncRegexp(regexp.MustCompile("[/+]json$"), json.Minify)
+	m.AddFuncRegexp(regexp.MustCompile("[/+]xml$"), xml.Minify)
+	return m
+}
+
+// canMinify reports whether the file would be minified.
+func (s *Site) canMinify(path string) bool {
+	_, ok := minifyTypes[filepath.Ext(path)]
+	return s.minifier != nil && ok
+}
+
+// minifyFile minifies the content of the file by its extension,
+// returning it unchanged if minification is off or fails.
+func (s *Site) minifyFile(path string, body []byte) []byte {
+	mediatype, ok := minifyTypes[filepath.Ext(path)]
+	if s.minifier == nil || !ok {
+		return body
+	}
+	out, err := s.minifier.Bytes(mediatype, body)
+	if err != nil {
+		return body
+	}
+	return out
+}
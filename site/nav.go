@@ -0,0 +1,144 @@
+package site
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// linkPages sets the Prev/Next links of the regular (non-index, non-404) pages,
+// in the order of the pages: Prev is the newer page, Next the older one.
+// PrevInSection/NextInSection only consider pages of the same section.
+// Pages are only linked to pages of the same language.
+// The links point into the pages slice, so it must not be modified later.
+func linkPages(pages Pages) {
+	prev := make(map[string]*Page)
+	prevInSection := make(map[string]*Page)
+	for i := range pages {
+		page := &pages[i]
+		if page.isIndex() || page.isNotFound() {
+			continue
+		}
+		if other := prev[page.Lang]; other != nil {
+			page.Prev = other
+			other.Next = page
+		}
+		prev[page.Lang] = page
+
+		section := page.Lang + "/" + page.Section
+		if other := prevInSection[section]; other != nil {
+			page.PrevInSection = other
+			other.NextInSection = page
+		}
+		prevInSection[section] = page
+	}
+}
+
+// relatePages sets the Related pages of every page: up to n pages
+// sharing the most tags with it, the newer first on equal counts.
+// Tags are compared by slug, as their pages are.
+func relatePages(pages Pages, n int) {
+	if n <= 0 {
+		return
+	}
+	// the pages of each tag, in the order of the pages
+	tagged := make(map[string][]int)
+	for i, page := range pages {
+		for _, tag := range pageTagSlugs(page) {
+			key := page.Lang + "/" + tag
+			tagged[key] = append(tagged[key], i)
+		}
+	}
+	for i := range pages {
+		page := &pages[i]
+		shared := make(map[int]int)
+		var candidates []int
+		for _, tag := range pageTagSlugs(*page) {
+			for _, j := range tagged[page.Lang+"/"+tag] {
+				if j == i {
+					continue
+				}
+				if shared[j] == 0 {
+					candidates = append(candidates, j)
+				}
+				shared[j]++
+			}
+		}
+		// pages are sorted by date already
+		sort.Ints(candidates)
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return shared[candidates[a]] > shared[candidates[b]]
+		})
+		if len(candidates) > n {
+			candidates = candidates[:n]
+		}
+		page.Related = nil
+		for _, j := range candidates {
+			page.Related = append(page.Related, &pages[j])
+		}
+	}
+}
+
+// pageTagSlugs returns the slugs of the tags of the page, once each.
+func pageTagSlugs(page Page) []string {
+	var slugs []string
+	seen := make(map[string]bool, len(page.Tags))
+	for _, tag := range page.Tags {
+		if slug := slugify(tag); slug != "" && !seen[slug] {
+			seen[slug] = true
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs
+}
+
+// Breadcrumb is one of the ancestors of a page.
+type Breadcrumb struct {
+	Title string
+	// URL is empty for directories without an index page.
+	URL string
+}
+
+// setBreadcrumbs sets the Breadcrumbs of every page: the home page,
+// each directory above the page, and the page itself. Directories
+// are named by the title of their index page or else their name.
+func setBreadcrumbs(pages Pages, cfg Config) {
+	indexes := make(map[string]*Page)
+	for i := range pages {
+		if page := &pages[i]; page.isIndex() {
+			indexes[page.Lang+":"+filepath.Dir(page.RelPath)] = page
+		}
+	}
+	for i := range pages {
+		page := &pages[i]
+		var dirs []string
+		for dir := filepath.Dir(page.RelPath); ; dir = filepath.Dir(dir) {
+			dirs = append([]string{dir}, dirs...)
+			if dir == "." {
+				break
+			}
+		}
+		if page.isIndex() {
+			dirs = dirs[:len(dirs)-1]
+		}
+
+		page.Breadcrumbs = nil
+		for _, dir := range dirs {
+			crumb := Breadcrumb{Title: filepath.Base(dir)}
+			if dir == "." {
+				crumb.Title = cfg.language(page.Lang).Title
+			}
+			if index := indexes[page.Lang+":"+dir]; index != nil {
+				crumb.URL = index.Url
+				if title := index.metaString("title"); title != "" {
+					crumb.Title = title
+				}
+			}
+			page.Breadcrumbs = append(page.Breadcrumbs, crumb)
+		}
+		title := page.metaString("title")
+		if title == "" {
+			title = page.filename()
+		}
+		page.Breadcrumbs = append(page.Breadcrumbs, Breadcrumb{Title: title, URL: page.Url})
+	}
+}
@@ -0,0 +1,58 @@
+package site
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRelatePages(t *testing.T) {
+	newPages := func() Pages {
+		return Pages{
+			{RelPath: "a.md", Tags: []string{"go", "web"}},
+			{RelPath: "b.md", Tags: []string{"Go"}},
+			{RelPath: "c.md", Tags: []string{"web", "go", "go"}},
+			{RelPath: "d.md", Tags: []string{"css"}},
+			{RelPath: "e.md"},
+			{RelPath: "f.fr.md", Lang: "fr", Tags: []string{"go", "web"}},
+		}
+	}
+	related := func(pages Pages) map[string][]string {
+		paths := make(map[string][]string)
+		for _, page := range pages {
+			for _, other := range page.Related {
+				paths[page.RelPath] = append(paths[page.RelPath], other.RelPath)
+			}
+		}
+		return paths
+	}
+
+	tests := []struct {
+		n    int
+		want map[string][]string
+	}{
+		{n: 0, want: map[string][]string{}},
+		{
+			n: 1,
+			want: map[string][]string{
+				"a.md": {"c.md"},
+				"b.md": {"a.md"},
+				"c.md": {"a.md"},
+			},
+		},
+		{
+			n: 5,
+			want: map[string][]string{
+				"a.md": {"c.md", "b.md"},
+				"b.md": {"a.md", "c.md"},
+				"c.md": {"a.md", "b.md"},
+			},
+		},
+	}
+	for _, test := range tests {
+		pages := newPages()
+		relatePages(pages, test.n)
+		if got := related(pages); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("relatePages(%d) = %v, want %v", test.n, got, test.want)
+		}
+	}
+}
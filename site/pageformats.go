@@ -0,0 +1,89 @@
+package site
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pageFormats are the formats pages can also be written in,
+// besides HTML, for client-side apps and search services.
+var pageFormats = map[string]bool{"json": true, "yaml": true}
+
+// pageData is a page as written in the other formats.
+type pageData struct {
+	Url         string                 `json:"url" yaml:"url"`
+	Lang        string                 `json:"lang,omitempty" yaml:"lang,omitempty"`
+	Section     string                 `json:"section,omitempty" yaml:"section,omitempty"`
+	LastMod     string                 `json:"lastmod,omitempty" yaml:"lastmod,omitempty"`
+	Meta        map[string]interface{} `json:"meta" yaml:"meta"`
+	Summary     string                 `json:"summary" yaml:"summary"`
+	HTML        string                 `json:"html" yaml:"html"`
+	Text        string                 `json:"text" yaml:"text"`
+	WordCount   int                    `json:"wordCount" yaml:"wordCount"`
+	ReadingTime int                    `json:"readingTime" yaml:"readingTime"`
+}
+
+// formats returns the formats the page is also written in: those
+// of its formats front matter field, or else of the pageFormats config.
+func (p Page) formats(cfg Config) []string {
+	if v, ok := p.Meta["formats"]; ok {
+		return metaList(v)
+	}
+	return cfg.PageFormats
+}
+
+// formatPath returns the path of the page in a format,
+// next to its HTML at outPath: posts/hello.json.
+func formatPath(outPath, format string) string {
+	return strings.TrimSuffix(outPath, filepath.Ext(outPath)) + "." + format
+}
+
+// writePageFormats writes the page, whose HTML is at outPath,
+// in each of its formats: its front matter, rendered HTML and
+// plain text.
+func (s *Site) writePageFormats(page *Page, outPath string, formats []string) error {
+	data := pageData{
+		Url:         page.Url,
+		Lang:        page.Lang,
+		Section:     page.Section,
+		Meta:        page.Meta,
+		Summary:     string(page.Summary),
+		HTML:        string(page.HTML),
+		Text:        plainify(string(page.HTML)),
+		WordCount:   page.WordCount,
+		ReadingTime: page.ReadingTime,
+	}
+	if data.Meta == nil {
+		data.Meta = map[string]interface{}{}
+	}
+	if !page.LastMod.IsZero() {
+		data.LastMod = page.LastMod.Format(time.RFC3339)
+	}
+	for _, format := range formats {
+		var body []byte
+		var err error
+		switch format {
+		case "json":
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			err = enc.Encode(data)
+			body = buf.Bytes()
+		case "yaml":
+			body, err = yaml.Marshal(data)
+		default:
+			return fmt.Errorf("unknown format %q, not json or yaml", format)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %s", format, err)
+		}
+		s.writeFile(formatPath(outPath, format), body)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package site
+
+import "strconv"
+
+// Paginator is a chunk of a paginated page list,
+// exposed to templates as .Paginator.
+type Paginator struct {
+	Pages      Pages
+	PageNumber int
+	TotalPages int
+	// Prev and Next are the urls of the neighbouring chunks.
+	// The url of the home page is empty, so check HasPrev/HasNext.
+	Prev string
+	Next string
+}
+
+func (p Paginator) HasPrev() bool { return p.PageNumber > 1 }
+func (p Paginator) HasNext() bool { return p.PageNumber < p.TotalPages }
+
+// paginate splits pages into chunks of the given size. The first chunk
+// is rendered at url, the following ones at url + "page/<n>/".
+func paginate(pages Pages, size int, url string) []Paginator {
+	total := (len(pages) + size - 1) / size
+	if total == 0 {
+		total = 1
+	}
+	pagers := make([]Paginator, total)
+	for i := range pagers {
+		lo, hi := i*size, (i+1)*size
+		if hi > len(pages) {
+			hi = len(pages)
+		}
+		pagers[i] = Paginator{
+			Pages:      pages[lo:hi],
+			PageNumber: i + 1,
+			TotalPages: total,
+		}
+		if i > 0 {
+			pagers[i].Prev = pagerURL(url, i)
+		}
+		if i < total-1 {
+			pagers[i].Next = pagerURL(url, i+2)
+		}
+	}
+	return pagers
+}
+
+func pagerURL(url string, n int) string {
+	if n == 1 {
+		return url
+	}
+	return url + "page/" + strconv.Itoa(n) + "/"
+}
@@ -0,0 +1,93 @@
+package site
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var permalinkToken = regexp.MustCompile(`:[a-z]+`)
+
+// permalink builds the url of a page from a pattern such as
+// /blog/:year/:month/:slug/, replacing :year, :month, :day,
+// :section, :filename, :slug (see Page.slug) and :title.
+func permalink(pattern string, page Page, cfg Config) (string, error) {
+	var err error
+	url := permalinkToken.ReplaceAllStringFunc(pattern, func(token string) string {
+		filename := page.filename()
+		switch token {
+		case ":year", ":month", ":day":
+			date, dateErr := page.date()
+			if dateErr != nil {
+				err = fmt.Errorf("%s needs a date: %s", token, dateErr)
+				return ""
+			}
+			return date.Format(map[string]string{
+				":year":  "2006",
+				":month": "01",
+				":day":   "02",
+			}[token])
+		case ":section":
+			return page.Section
+		case ":filename":
+			return filename
+		case ":slug":
+			return page.slug(cfg.Slugify)
+		case ":title":
+			if title := page.metaString("title"); title != "" {
+				return slugify(title)
+			}
+			return slugify(filename)
+		}
+		err = fmt.Errorf("unknown permalink token %s", token)
+		return ""
+	})
+	if err != nil {
+		return "", err
+	}
+	// urls are relative to the site root
+	url = strings.TrimPrefix(url, "/")
+	if url != "" && !strings.HasSuffix(url, "/") && filepath.Ext(url) == "" {
+		url += ".html"
+	}
+	return url, nil
+}
+
+// pageURL returns the url of a page: the one set by the page for
+// compat, or the permalink pattern of its section if any, or else
+// its path with the file name replaced by the slug. Pages in languages other than the default one are put
+// under the language code.
+func pageURL(page Page, cfg Config) (string, error) {
+	url, ok, err := compatURL(page, cfg)
+	if err != nil {
+		return "", err
+	}
+	pattern, hasPattern := cfg.Permalinks[page.Section]
+	switch {
+	case ok:
+		// the page's own url
+	case hasPattern && !page.isIndex() && !page.isNotFound():
+		if url, err = permalink(pattern, page, cfg); err != nil {
+			return "", err
+		}
+	default:
+		if dir := filepath.Dir(page.RelPath); dir != "." {
+			url = filepath.ToSlash(dir) + "/"
+		}
+		switch {
+		case page.isIndex():
+		case page.isNotFound():
+			// static hosts look for 404.html
+			url += "404.html"
+		case cfg.UglyURLs:
+			url += page.slug(cfg.Slugify) + ".html"
+		default:
+			url += page.slug(cfg.Slugify) + "/"
+		}
+	}
+	if page.Lang != cfg.DefaultLanguage {
+		url = page.Lang + "/" + url
+	}
+	return url, nil
+}
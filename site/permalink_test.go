@@ -0,0 +1,51 @@
+package site
+
+import "testing"
+
+func TestPermalink(t *testing.T) {
+	post := Page{
+		RelPath: "posts/my-post.md",
+		Section: "posts",
+		Meta:    map[string]interface{}{"title": "Hello World", "date": "2024-03-05"},
+	}
+	slugged := post
+	slugged.Meta = map[string]interface{}{"title": "Hello World", "slug": "Custom Slug"}
+	undated := post
+	undated.Meta = map[string]interface{}{"title": "Hello World"}
+	translated := post
+	translated.RelPath = "posts/my-post.fr.md"
+	translated.Lang = "fr"
+
+	tests := []struct {
+		pattern string
+		page    Page
+		slugify bool
+		want    string
+		err     bool
+	}{
+		{pattern: "/blog/:year/:month/:day/:slug/", page: post, want: "blog/2024/03/05/my-post/"},
+		{pattern: "/:section/:filename", page: post, want: "posts/my-post.html"},
+		{pattern: "/:section/:title/", page: post, want: "posts/hello-world/"},
+		{pattern: "/:slug/", page: post, slugify: true, want: "hello-world/"},
+		{pattern: "/:slug/", page: slugged, want: "custom-slug/"},
+		{pattern: "/:slug/", page: translated, want: "my-post/"},
+		{pattern: "/:slug.xml", page: post, want: "my-post.xml"},
+		{pattern: "/", page: post, want: ""},
+		{pattern: "/:year/:slug/", page: undated, err: true},
+		{pattern: "/:author/", page: post, err: true},
+	}
+	for _, test := range tests {
+		got, err := permalink(test.pattern, test.page, Config{Slugify: test.slugify})
+		if test.err {
+			if err == nil {
+				t.Errorf("permalink(%q, %s): expected an error, got %q", test.pattern, test.page.RelPath, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("permalink(%q, %s): %s", test.pattern, test.page.RelPath, err)
+		} else if got != test.want {
+			t.Errorf("permalink(%q, %s) = %q, want %q", test.pattern, test.page.RelPath, got, test.want)
+		}
+	}
+}
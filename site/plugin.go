@@ -0,0 +1,108 @@
+package site
+
+import (
+	"fmt"
+	"html/template"
+	"path/filepath"
+
+	"github.com/yuin/goldmark"
+)
+
+// Plugin extends the build pipeline, for programs building sites
+// with the package. All its fields are optional. Content and HTML
+// are called for several pages at once, so they must be safe
+// for concurrent use.
+type Plugin struct {
+	// Name identifies the plugin in errors.
+	Name string
+	// Content transforms the markdown of a page before it is
+	// parsed, after its shortcodes are expanded.
+	Content func(page *Page, text []byte) ([]byte, error)
+	// Markdown extends the markdown converter, with parser options
+	// such as AST transformers or renderers of new nodes.
+	Markdown []goldmark.Extender
+	// Funcs are added to the template functions,
+	// replacing marc's own of the same name.
+	Funcs template.FuncMap
+	// Renderers convert the pages of other formats than markdown,
+	// by file extension such as ".adoc", to HTML.
+	Renderers map[string]Renderer
+	// HTML filters the rendered HTML of each page.
+	HTML func(page *Page, html []byte) ([]byte, error)
+	// Generate writes more output once the pages are rendered,
+	// write taking slash-separated paths in the output directory.
+	Generate func(pages Pages, cfg Config, write func(relpath string, data []byte)) error
+}
+
+var plugins []Plugin
+
+// Register adds a plugin to the builds that follow. Plugins run
+// in the order they were registered.
+func Register(p Plugin) {
+	plugins = append(plugins, p)
+	for name, fn := range p.Funcs {
+		funcs[name] = fn
+	}
+	for ext, r := range p.Renderers {
+		renderers[ext] = r
+	}
+}
+
+// pluginExtensions returns the markdown extensions of the plugins.
+func pluginExtensions() []goldmark.Extender {
+	var extensions []goldmark.Extender
+	for _, p := range plugins {
+		extensions = append(extensions, p.Markdown...)
+	}
+	return extensions
+}
+
+// transformContent passes the markdown of a page through the plugins.
+func transformContent(page *Page, text []byte) ([]byte, error) {
+	for _, p := range plugins {
+		if p.Content == nil {
+			continue
+		}
+		var err error
+		if text, err = p.Content(page, text); err != nil {
+			return nil, pluginError(p, err)
+		}
+	}
+	return text, nil
+}
+
+// filterHTML passes the rendered HTML of a page through the plugins.
+func filterHTML(page *Page, html []byte) ([]byte, error) {
+	for _, p := range plugins {
+		if p.HTML == nil {
+			continue
+		}
+		var err error
+		if html, err = p.HTML(page, html); err != nil {
+			return nil, pluginError(p, err)
+		}
+	}
+	return html, nil
+}
+
+// generatePlugins has the plugins write their output.
+func (s *Site) generatePlugins(outDir string, cfg Config, pages Pages) {
+	write := func(relpath string, data []byte) {
+		s.writeFile(filepath.Join(outDir, filepath.FromSlash(relpath)), data)
+	}
+	for _, p := range plugins {
+		if p.Generate == nil {
+			continue
+		}
+		if err := p.Generate(pages, cfg, write); err != nil {
+			fatal(pluginError(p, err))
+		}
+	}
+}
+
+func pluginError(p Plugin, err error) error {
+	if p.Name == "" {
+		return err
+	}
+	return fmt.Errorf("plugin %s: %s", p.Name, err)
+}
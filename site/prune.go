@@ -0,0 +1,136 @@
+package site
+
+import (
+	"encoding/json"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// outputSet records the files of the output a build writes or
+// leaves unchanged, to prune the others.
+type outputSet struct {
+	mu    sync.Mutex
+	files map[string]bool
+}
+
+func (o *outputSet) reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.files = make(map[string]bool)
+}
+
+func (o *outputSet) add(path string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.files != nil {
+		o.files[filepath.Clean(path)] = true
+	}
+}
+
+func (o *outputSet) has(path string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.files[filepath.Clean(path)]
+}
+
+// names returns the paths of the files relative to outDir,
+// slash-separated and sorted.
+func (o *outputSet) names(outDir string) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	names := make([]string, 0, len(o.files))
+	for path := range o.files {
+		if name, err := fsName(outDir, path); err == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// prune removes the files of outDir that keep doesn't report, along
+// with the directories left empty, or only logs them in a dry run.
+// Hidden files and directories, such as .git, are left alone, as
+// are the variants of the images kept, which pages left unchanged
+// may still use. The compressed copies of the files are only kept
+// if reported, so that they go once compression is turned off.
+func (s *Site) prune(outDir string, keep func(path string) bool) {
+	var stale []string
+	err := s.walkOutput(func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != outDir && isHidden(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || keep(path) {
+			return nil
+		}
+		if isVariant(path, keep) {
+			return nil
+		}
+		stale = append(stale, path)
+		return nil
+	})
+	if err != nil {
+		fatalIO("failed to read output:", err)
+	}
+	for _, path := range stale {
+		if s.dryRun {
+			s.logChange("remove", path)
+			continue
+		}
+		s.logf("- %s", path)
+		if err := s.removeOutput(path); err != nil {
+			fatalIO("failed to remove stale file:", err)
+		}
+	}
+}
+
+// cleanStale prunes the files of outDir missing from the
+// outputs of the build cache of the last build.
+func (s *Site) cleanStale(outDir string) {
+	text, err := s.readOutput(filepath.Join(outDir, cacheFile))
+	if err != nil {
+		fatalf("no build cache in %s to tell the stale files from: build the site first", outDir)
+	}
+	var cache buildCache
+	if err := json.Unmarshal(text, &cache); err != nil {
+		fatalf("failed to read build cache: %s", err)
+	}
+	if cache.Outputs == nil {
+		fatalf("the build cache in %s doesn't list the output files: build the site again", outDir)
+	}
+	kept := make(map[string]bool, len(cache.Outputs))
+	for _, name := range cache.Outputs {
+		kept[filepath.Join(outDir, filepath.FromSlash(name))] = true
+	}
+	s.prune(outDir, func(path string) bool { return kept[filepath.Clean(path)] })
+}
+
+// variantPattern matches the path of an image variant,
+// whose width, if any, is between the name and extension.
+var variantPattern = regexp.MustCompile(`^(.*?)(?:\.\d+w)?\.(?:jpe?g|png|webp|avif)$`)
+
+// isVariant reports whether path is a variant of a JPEG
+// or PNG image kept, as variantName names them.
+func isVariant(path string, keep func(path string) bool) bool {
+	m := variantPattern.FindStringSubmatch(strings.ToLower(path))
+	if m == nil {
+		return false
+	}
+	base := path[:len(m[1])]
+	for _, ext := range []string{".jpg", ".jpeg", ".png", ".JPG", ".JPEG", ".PNG"} {
+		if orig := base + ext; orig != path && keep(orig) {
+			return true
+		}
+	}
+	return false
+}
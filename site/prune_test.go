@@ -0,0 +1,74 @@
+package site
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIsVariant(t *testing.T) {
+	kept := map[string]bool{"img/photo.jpg": true, "img/logo.PNG": true}
+	keep := func(path string) bool { return kept[path] }
+	tests := map[string]bool{
+		"img/photo.800w.jpg":  true,
+		"img/photo.webp":      true,
+		"img/photo.400w.avif": true,
+		"img/logo.400w.webp":  true,
+		"img/logo.png":        true,
+		"img/photo.jpg":       false,
+		"img/logo.PNG":        false,
+		"img/other.800w.jpg":  false,
+		"img/photo.800w.gif":  false,
+		"img/photo.txt":       false,
+		"img/photo.x.webp":    false,
+		"photo.800w.jpg":      false,
+	}
+	for path, want := range tests {
+		if got := isVariant(path, keep); got != want {
+			t.Errorf("isVariant(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestPruneCompressed(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression CompressConfig
+		want        []string
+	}{
+		{name: "off", want: []string{"a.html", "img/photo.jpg"}},
+		{name: "gzip", compression: CompressConfig{Gzip: true, Types: []string{".html"}}, want: []string{"a.html", "a.html.gz", "img/photo.jpg"}},
+		{name: "both", compression: CompressConfig{Gzip: true, Brotli: true, Types: []string{".html"}}, want: []string{"a.html", "a.html.br", "a.html.gz", "img/photo.jpg"}},
+	}
+	for _, test := range tests {
+		outDir := t.TempDir()
+		for _, name := range []string{"a.html", "a.html.gz", "a.html.br", "b.html.gz", "img/photo.jpg", "img/photo.jpg.gz"} {
+			path := filepath.Join(outDir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s := New(t.TempDir(), outDir, Config{Compress: test.compression})
+		s.outputs.reset()
+		s.keepOutput(filepath.Join(outDir, "a.html"))
+		s.keepOutput(filepath.Join(outDir, "img", "photo.jpg"))
+		s.prune(outDir, s.outputs.has)
+
+		var got []string
+		filepath.WalkDir(outDir, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				name, _ := filepath.Rel(outDir, path)
+				got = append(got, filepath.ToSlash(name))
+			}
+			return err
+		})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: left %q, want %q", test.name, got, test.want)
+		}
+	}
+}
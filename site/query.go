@@ -0,0 +1,201 @@
+package site
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The functions below query page lists in templates, e.g.
+// {{ range .Pages | under "blog/" | where "draft" false | limit 5 }}.
+
+// PageGroup is a set of pages sharing the same key, see groupBy.
+type PageGroup struct {
+	Key   string
+	Pages Pages
+}
+
+// where returns the pages whose front matter value for key equals
+// value, or contains it if the front matter value is a list.
+func where(key string, value interface{}, pages Pages) Pages {
+	want := fmt.Sprint(value)
+	result := make(Pages, 0)
+	for _, page := range pages {
+		switch v := page.Meta[key].(type) {
+		case []interface{}:
+			for _, item := range v {
+				if fmt.Sprint(item) == want {
+					result = append(result, page)
+					break
+				}
+			}
+		case nil:
+			if value == nil {
+				result = append(result, page)
+			}
+		default:
+			if page.metaString(key) == want || fmt.Sprint(v) == want {
+				result = append(result, page)
+			}
+		}
+	}
+	return result
+}
+
+// under returns the pages located under the given path prefix.
+func under(prefix string, pages Pages) Pages {
+	prefix = strings.TrimPrefix(prefix, "/")
+	result := make(Pages, 0)
+	for _, page := range pages {
+		if strings.HasPrefix(page.Url, prefix) {
+			result = append(result, page)
+		}
+	}
+	return result
+}
+
+// sortBy sorts the pages by a front matter key in "asc" or "desc"
+// order. Dates and numbers are compared as such, other values as
+// strings. The original order is kept for equal values.
+func sortBy(key, order string, pages Pages) (Pages, error) {
+	if order != "asc" && order != "desc" {
+		return nil, fmt.Errorf("unknown sort order: %s", order)
+	}
+	result := make(Pages, len(pages))
+	copy(result, pages)
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if order == "desc" {
+			a, b = b, a
+		}
+		return lessBy(key, a, b)
+	})
+	return result, nil
+}
+
+// SortConfig sets the order of the page lists.
+type SortConfig struct {
+	// By is the key pages are sorted by: date, weight, title
+	// or any other front matter field.
+	By string `toml:"by" yaml:"by"`
+	// Order is asc or desc, by default desc for dates
+	// and asc for the other keys.
+	Order string `toml:"order" yaml:"order"`
+	// Then is the key, in ascending order, sorting
+	// the pages with the same By.
+	Then string `toml:"then" yaml:"then"`
+}
+
+// sortPages sorts the pages by the By key in order, the pages
+// without it last, then by the Then key and then by path.
+func sortPages(pages Pages, s SortConfig) {
+	desc := s.Order == "desc" || s.Order == "" && s.By == "date"
+	sort.SliceStable(pages, func(i, j int) bool {
+		a, b := pages[i], pages[j]
+		if ha, hb := hasKey(s.By, a), hasKey(s.By, b); ha != hb {
+			return ha
+		}
+		if c := compareBy(s.By, a, b); c != 0 {
+			return c < 0 != desc
+		}
+		if s.Then != "" {
+			if c := compareBy(s.Then, a, b); c != 0 {
+				return c < 0
+			}
+		}
+		return a.RelPath < b.RelPath
+	})
+}
+
+// sortConfig returns the sort front matter map of the page,
+// sorting the pages of its section, if any.
+func (p Page) sortConfig() (SortConfig, bool) {
+	m, ok := p.Meta["sort"].(map[string]interface{})
+	if !ok {
+		return SortConfig{}, false
+	}
+	s := SortConfig{By: "date"}
+	if by, ok := m["by"].(string); ok {
+		s.By = by
+	}
+	s.Order, _ = m["order"].(string)
+	s.Then, _ = m["then"].(string)
+	return s, true
+}
+
+// hasKey reports whether the page has a value for the key.
+func hasKey(key string, p Page) bool {
+	if key == "date" {
+		return !p.Date.IsZero()
+	}
+	return p.Meta[key] != nil
+}
+
+// compareBy returns -1, 0 or 1 as the value of the key
+// of a is less than, equal to or greater than b's.
+func compareBy(key string, a, b Page) int {
+	switch {
+	case lessBy(key, a, b):
+		return -1
+	case lessBy(key, b, a):
+		return 1
+	}
+	return 0
+}
+
+func lessBy(key string, a, b Page) bool {
+	if key == "date" {
+		da, _ := a.date()
+		db, _ := b.date()
+		return da.Before(db)
+	}
+	va, vb := a.metaString(key), b.metaString(key)
+	fa, erra := strconv.ParseFloat(va, 64)
+	fb, errb := strconv.ParseFloat(vb, 64)
+	if erra == nil && errb == nil {
+		return fa < fb
+	}
+	return va < vb
+}
+
+// groupBy groups the pages by "year" or "month" of their date,
+// or by the value of any other front matter key, keeping the order
+// in which the groups first appear.
+func groupBy(key string, pages Pages) []PageGroup {
+	var groups []PageGroup
+	index := make(map[string]int)
+	for _, page := range pages {
+		var k string
+		switch key {
+		case "year", "month":
+			date, err := page.date()
+			if err != nil {
+				break
+			}
+			if key == "year" {
+				k = date.Format("2006")
+			} else {
+				k = date.Format("2006-01")
+			}
+		default:
+			k = page.metaString(key)
+		}
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, PageGroup{Key: k})
+		}
+		groups[i].Pages = append(groups[i].Pages, page)
+	}
+	return groups
+}
+
+// limit returns at most the first n pages.
+func limit(n int, pages Pages) Pages {
+	if n < len(pages) {
+		return pages[:n]
+	}
+	return pages
+}
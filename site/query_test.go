@@ -0,0 +1,75 @@
+package site
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSortPages(t *testing.T) {
+	page := func(path, date string, meta map[string]interface{}) Page {
+		p := Page{RelPath: path, Meta: meta}
+		if p.Meta == nil {
+			p.Meta = make(map[string]interface{})
+		}
+		if date != "" {
+			p.Date, _ = time.Parse("2006-01-02", date)
+			p.Meta["date"] = p.Date
+		}
+		return p
+	}
+	pages := Pages{
+		page("c.md", "2021-01-01", map[string]interface{}{"weight": 10, "title": "Gamma"}),
+		page("a.md", "2023-01-01", map[string]interface{}{"weight": 2, "title": "Alpha"}),
+		page("d.md", "", map[string]interface{}{"title": "Delta"}),
+		page("b.md", "2021-01-01", map[string]interface{}{"weight": 2, "title": "Beta"}),
+	}
+	tests := []struct {
+		sort SortConfig
+		want []string
+	}{
+		{sort: SortConfig{By: "date"}, want: []string{"a.md", "b.md", "c.md", "d.md"}},
+		{sort: SortConfig{By: "date", Order: "asc"}, want: []string{"b.md", "c.md", "a.md", "d.md"}},
+		{sort: SortConfig{By: "date", Then: "title"}, want: []string{"a.md", "b.md", "c.md", "d.md"}},
+		{sort: SortConfig{By: "date", Then: "weight"}, want: []string{"a.md", "b.md", "c.md", "d.md"}},
+		{sort: SortConfig{By: "weight"}, want: []string{"a.md", "b.md", "c.md", "d.md"}},
+		{sort: SortConfig{By: "weight", Then: "title"}, want: []string{"a.md", "b.md", "c.md", "d.md"}},
+		{sort: SortConfig{By: "weight", Order: "desc", Then: "title"}, want: []string{"c.md", "a.md", "b.md", "d.md"}},
+		{sort: SortConfig{By: "title", Order: "desc"}, want: []string{"c.md", "d.md", "b.md", "a.md"}},
+		{sort: SortConfig{By: "missing"}, want: []string{"a.md", "b.md", "c.md", "d.md"}},
+	}
+	for _, test := range tests {
+		sorted := append(Pages(nil), pages...)
+		sortPages(sorted, test.sort)
+		var got []string
+		for _, p := range sorted {
+			got = append(got, p.RelPath)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v: got %v, want %v", test.sort, got, test.want)
+		}
+	}
+}
+
+func TestSortConfig(t *testing.T) {
+	tests := []struct {
+		meta map[string]interface{}
+		want SortConfig
+		ok   bool
+	}{
+		{meta: map[string]interface{}{}, ok: false},
+		{meta: map[string]interface{}{"sort": "title"}, ok: false},
+		{meta: map[string]interface{}{"sort": map[string]interface{}{}}, want: SortConfig{By: "date"}, ok: true},
+		{
+			meta: map[string]interface{}{"sort": map[string]interface{}{"by": "weight", "order": "desc", "then": "title"}},
+			want: SortConfig{By: "weight", Order: "desc", Then: "title"},
+			ok:   true,
+		},
+	}
+	for _, test := range tests {
+		got, ok := Page{Meta: test.meta}.sortConfig()
+		if got != test.want || ok != test.ok {
+			t.Errorf("%v: got %+v, %v, want %+v, %v", test.meta, got, ok, test.want, test.ok)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RemoteSource is content fetched at build time and merged into
+// the site: a file at URL, a list of files at URL, in the json
+// Format, or the files of the git repository Git.
+type RemoteSource struct {
+	URL string `toml:"url" yaml:"url"`
+	// Format is file, the default, or json for an array
+	// of {"path": ..., "content": ...} objects.
+	Format string `toml:"format" yaml:"format"`
+	Git    string `toml:"git" yaml:"git"`
+	// Ref is the branch, tag or commit checked out,
+	// the default branch if empty.
+	Ref string `toml:"ref" yaml:"ref"`
+	// Dir is the directory of the repository with the content.
+	Dir string `toml:"dir" yaml:"dir"`
+	// Path is where the content goes in the site: the file
+	// fetched from URL, or the directory of the other files.
+	Path string `toml:"path" yaml:"path"`
+}
+
+// remoteDir holds the copies of the remote sources in the site,
+// used when offline.
+const remoteDir = ".marc-remote"
+
+// remoteMeta is saved next to the copy of a remote file
+// to fetch it again only if it changed.
+type remoteMeta struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+var (
+	// fetched lists the sources fetched already, so that
+	// rebuilds of the same run use their copies.
+	fetched   = make(map[string]bool)
+	fetchedMu sync.Mutex
+)
+
+// fetchRemote fetches the remote sources of the config, unless
+// offline, and returns their copies as content directories.
+func (s *Site) fetchRemote(siteDir string, cfg Config) []contentRoot {
+	if len(cfg.Remote) == 0 {
+		return nil
+	}
+	dir, err := s.diskPath(filepath.Join(siteDir, remoteDir))
+	if err != nil {
+		fatalf("remote sources: %s", err)
+	}
+	var roots []contentRoot
+	for _, src := range cfg.Remote {
+		root, err := s.fetchSource(src, dir, cfg.Offline)
+		if err != nil {
+			fatalIO(fmt.Sprintf("failed to fetch %s: %s", src.name(), err))
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+func (src RemoteSource) name() string {
+	if src.Git != "" {
+		return strings.TrimSuffix(src.Git+"@"+src.Ref, "@")
+	}
+	return src.URL
+}
+
+// fetchSource updates the copy of the source in dir, falling back
+// to the copy if fetching fails, and returns it as a content
+// directory.
+func (s *Site) fetchSource(src RemoteSource, dir string, offline bool) (contentRoot, error) {
+	copyDir := filepath.Join(dir, hashBytes([]byte(src.name()), []byte(src.Format), []byte(src.Path))[:16])
+	root := contentRoot{dir: copyDir, mount: filepath.FromSlash(src.Path)}
+	switch {
+	case src.Git != "":
+		root.dir = filepath.Join(copyDir, filepath.FromSlash(src.Dir))
+	case src.URL == "":
+		return root, fmt.Errorf("remote source with no url or git repository")
+	case src.Format == "" || src.Format == "file":
+		if src.Path == "" {
+			return root, fmt.Errorf("%s: no path in the site", src.URL)
+		}
+		root.mount = ""
+	case src.Format != "json":
+		return root, fmt.Errorf("unknown format %q", src.Format)
+	}
+
+	fetchedMu.Lock()
+	defer fetchedMu.Unlock()
+	_, err := os.Stat(copyDir)
+	cached := err == nil
+	if offline || fetched[copyDir] {
+		if !cached {
+			return root, fmt.Errorf("no copy to build offline")
+		}
+		return root, nil
+	}
+	if src.Git != "" {
+		err = src.fetchGit(copyDir)
+	} else {
+		err = s.fetchURL(src, copyDir)
+	}
+	if err != nil {
+		if !cached {
+			return root, err
+		}
+		s.logWarning("failed to fetch %s, using the copy: %s", src.name(), err)
+	}
+	fetched[copyDir] = true
+	return root, nil
+}
+
+// fetchGit checks out the ref of the repository in dir.
+func (src RemoteSource) fetchGit(dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := git(dir, "init", "--quiet"); err != nil {
+			return err
+		}
+	}
+	ref := src.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	// git would read them as its options, some running commands
+	for _, arg := range []string{src.Git, ref} {
+		if strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("refusing to fetch %q: it starts with a dash", arg)
+		}
+	}
+	if err := git(dir, "fetch", "--quiet", "--depth", "1", "--", src.Git, ref); err != nil {
+		return err
+	}
+	return git(dir, "checkout", "--quiet", "--force", "FETCH_HEAD")
+}
+
+func git(dir string, args ...string) error {
+	cmd := exec.Command("git", append([]string{"-c", "advice.detachedHead=false", "-c", "protocol.ext.allow=never"}, args...)...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %s %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// fetchURL fetches the file, or the files, at the url into dir,
+// if changed since fetched last.
+func (s *Site) fetchURL(src RemoteSource, dir string) error {
+	metaPath := dir + ".json"
+	var meta remoteMeta
+	if data, err := os.ReadFile(metaPath); err == nil {
+		json.Unmarshal(data, &meta)
+	}
+	req, err := http.NewRequest("GET", src.URL, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); err == nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		s.logDebug("= %s unchanged", src.URL)
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{src.Path: body}
+	if src.Format == "json" {
+		var list []struct {
+			Path    string `json:"path"`
+			Content string `json:"content"`
+		}
+		if err := json.Unmarshal(body, &list); err != nil {
+			return err
+		}
+		files = make(map[string][]byte, len(list))
+		for _, file := range list {
+			files[file.Path] = []byte(file.Content)
+		}
+	}
+
+	// the files are written anew, leaving out those removed
+	tmp := dir + ".tmp"
+	os.RemoveAll(tmp)
+	for name, content := range files {
+		path := filepath.Join(tmp, filepath.FromSlash(name))
+		if name == "" || !isWithin(path, tmp) || path == tmp {
+			return fmt.Errorf("bad path %q", name)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return err
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return err
+	}
+	s.logDebug("fetched %s", src.URL)
+	meta = remoteMeta{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metaPath, data, 0644)
+}
@@ -0,0 +1,111 @@
+package site
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFetchRemote(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page.md", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("# Page"))
+	})
+	mux.HandleFunc("/pages.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"path": "a.md", "content": "A"}, {"path": "sub/b.md", "content": "B"}]`))
+	})
+	mux.HandleFunc("/escape.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"path": "../a.md", "content": "A"}]`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		src   RemoteSource
+		mount string
+		files map[string]string
+		err   bool
+	}{
+		{
+			name:  "file",
+			src:   RemoteSource{URL: server.URL + "/page.md", Path: "docs/page.md"},
+			files: map[string]string{"docs/page.md": "# Page"},
+		},
+		{
+			name:  "json",
+			src:   RemoteSource{URL: server.URL + "/pages.json", Format: "json", Path: "notes"},
+			mount: "notes",
+			files: map[string]string{"a.md": "A", "sub/b.md": "B"},
+		},
+		{name: "file with no path", src: RemoteSource{URL: server.URL + "/page.md"}, err: true},
+		{name: "unknown format", src: RemoteSource{URL: server.URL + "/page.md", Format: "xml"}, err: true},
+		{name: "no url", src: RemoteSource{Path: "a.md"}, err: true},
+		{name: "path outside", src: RemoteSource{URL: server.URL + "/escape.json", Format: "json"}, err: true},
+		{name: "not found", src: RemoteSource{URL: server.URL + "/missing.md", Path: "a.md"}, err: true},
+	}
+	s := newSite(fstest.MapFS{}, ".", false, nil, "", Config{})
+	for _, test := range tests {
+		dir := t.TempDir()
+		root, err := s.fetchSource(test.src, dir, false)
+		if (err != nil) != test.err {
+			t.Errorf("%s: got error %v, want error %v", test.name, err, test.err)
+			continue
+		}
+		if test.err {
+			continue
+		}
+		if root.mount != filepath.FromSlash(test.mount) {
+			t.Errorf("%s: mounted at %q, want %q", test.name, root.mount, test.mount)
+		}
+		for name, want := range test.files {
+			got, err := os.ReadFile(filepath.Join(root.dir, filepath.FromSlash(name)))
+			if err != nil || string(got) != want {
+				t.Errorf("%s: %s is %q (%v), want %q", test.name, name, got, err, want)
+			}
+		}
+	}
+}
+
+func TestFetchRemoteCopy(t *testing.T) {
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	s := newSite(fstest.MapFS{}, ".", false, nil, "", Config{})
+	dir := t.TempDir()
+	src := RemoteSource{URL: server.URL + "/a.txt", Path: "a.txt"}
+	if _, err := s.fetchSource(src, dir, true); err == nil {
+		t.Error("offline with no copy: got no error, want one")
+	}
+	if _, err := s.fetchSource(src, dir, false); err != nil {
+		t.Fatal(err)
+	}
+	// the copy is used when fetching fails
+	fail = true
+	fetched = make(map[string]bool)
+	root, err := s.fetchSource(src, dir, false)
+	if err != nil {
+		t.Fatalf("failed fetch with a copy: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(root.dir, "a.txt")); string(got) != "hello" {
+		t.Errorf("copy is %q, want %q", got, "hello")
+	}
+	if _, err := s.fetchSource(src, dir, true); err != nil {
+		t.Errorf("offline with a copy: %v", err)
+	}
+}
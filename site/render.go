@@ -0,0 +1,302 @@
+package site
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Renderer converts the pages of a format other than markdown to
+// HTML. Render is called for several pages at once, so it must be
+// safe for concurrent use.
+type Renderer struct {
+	// Meta reads the metadata of the format, if any, as the
+	// front matter of pages without one, returning the rest
+	// of the text. Optional.
+	Meta func(text []byte) (map[string]interface{}, []byte, error)
+	// Render converts the text of a page to HTML.
+	Render func(page *Page, text []byte) ([]byte, error)
+}
+
+// renderers are the renderers of the other formats by file
+// extension, the built-in ones and those of the plugins.
+// The built-in ones other than HTML's only read the metadata,
+// rendering with the commands of the renderers config.
+var renderers = map[string]Renderer{
+	".adoc":     {Meta: asciidocMeta},
+	".asciidoc": {Meta: asciidocMeta},
+	".org":      {Meta: orgMeta},
+	".html":     {Render: rawHTML},
+}
+
+// asciidoctor is the default command rendering AsciiDoc pages.
+const asciidoctor = "asciidoctor --embedded --out-file - -"
+
+// pandocOrg is the default command rendering Org pages.
+const pandocOrg = "pandoc --from org --to html"
+
+// Markdown is the content type of the pages converted
+// as markdown, in the contentTypes config.
+const Markdown = "markdown"
+
+// contentType returns the content type of the files with the
+// extension: Markdown, or the extension whose renderer converts
+// them, their own unless the contentTypes config maps it.
+func (s *Site) contentType(ext string) string {
+	if t := s.cfg.ContentTypes[ext]; t != "" {
+		return t
+	}
+	return ext
+}
+
+// isMarkdown reports whether the files with the
+// extension are converted as markdown.
+func (s *Site) isMarkdown(ext string) bool {
+	return s.contentType(ext) == Markdown
+}
+
+// pageRenderer returns the renderer of the pages with the extension,
+// if they are not markdown: the renderer of a plugin, or else the
+// command of the renderers config, for the extension or for its
+// content type.
+func (s *Site) pageRenderer(ext string) (Renderer, bool) {
+	t := s.contentType(ext)
+	if t == Markdown {
+		return Renderer{}, false
+	}
+	r := renderers[t]
+	command := s.cfg.Renderers[ext]
+	if command == "" {
+		command = s.cfg.Renderers[t]
+	}
+	if r.Render == nil && command != "" {
+		r.Render = s.commandRenderer(command)
+	}
+	return r, r.Render != nil
+}
+
+// isPage reports whether the file at path is a page, in markdown,
+// a format with a renderer, or HTML with front matter, the other
+// HTML files being copied as they are.
+func (s *Site) isPage(path string) bool {
+	ext := filepath.Ext(path)
+	switch s.contentType(ext) {
+	case Markdown:
+		return true
+	case ".html":
+		return s.hasFrontMatter(path)
+	default:
+		_, ok := s.pageRenderer(ext)
+		return ok
+	}
+}
+
+// hasFrontMatter reports whether the file at path starts
+// with a front matter delimiter.
+func (s *Site) hasFrontMatter(path string) bool {
+	f, err := s.openSource(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	delim := make([]byte, 3)
+	if _, err := io.ReadFull(f, delim); err != nil {
+		return false
+	}
+	return string(delim) == "---" || string(delim) == "+++"
+}
+
+// rawHTML renders HTML pages: their text is their HTML.
+func rawHTML(page *Page, text []byte) ([]byte, error) {
+	return text, nil
+}
+
+// commandRenderer renders pages with a shell command,
+// reading the text on stdin and writing HTML to stdout.
+// It runs in the directory of the page, or in an empty
+// temporary one if the site isn't on disk.
+func (s *Site) commandRenderer(command string) func(page *Page, text []byte) ([]byte, error) {
+	return func(page *Page, text []byte) ([]byte, error) {
+		cmd := shellCommand(command)
+		if path, err := s.diskPath(page.AbsPath); err == nil {
+			cmd.Dir = filepath.Dir(path)
+		} else {
+			dir, err := os.MkdirTemp("", "marc-render-")
+			if err != nil {
+				return nil, err
+			}
+			defer os.RemoveAll(dir)
+			cmd.Dir = dir
+		}
+		cmd.Stdin = bytes.NewReader(text)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("%q failed: %s %s", command, err, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return out, nil
+	}
+}
+
+// convertPage renders the text of the page to HTML with its renderer,
+// or as markdown with its shortcodes expanded, setting the fields
+// derived from it. For markdown pages, resolve, if not nil, is
+// called with the document to rewrite its links before rendering.
+func (s *Site) convertPage(md goldmark.Markdown, buf *bytes.Buffer, page *Page, shortcodes map[string]*template.Template, cfg Config, resolve func(doc ast.Node, page *Page) error) error {
+	if r, ok := s.pageRenderer(filepath.Ext(page.AbsPath)); ok {
+		return s.renderPage(r, page, cfg.SummaryParagraphs)
+	}
+	buf.Reset()
+	expanded, err := s.expandShortcodes(shortcodes, page.Text, page)
+	if err != nil {
+		return fmt.Errorf("failed to expand shortcodes: %s", err)
+	}
+	page.Text = expanded
+	if page.Text, err = transformContent(page, page.Text); err != nil {
+		return err
+	}
+	doc := md.Parser().Parse(text.NewReader(page.Text))
+	if resolve != nil {
+		if err := resolve(doc, page); err != nil {
+			return err
+		}
+	}
+	page.Mermaid = hasMermaid(doc)
+	page.TOC = buildTOC(doc, page.Text)
+	page.WordCount = countWords(doc, page.Text)
+	page.ReadingTime = readingTime(page.WordCount)
+	if err := md.Renderer().Render(buf, page.Text, doc); err != nil {
+		return fmt.Errorf("failed to convert markdown: %s", err)
+	}
+	page.HTML = template.HTML(buf.String())
+	summary, err := s.summarize(md, page, doc, cfg.SummaryParagraphs)
+	if err != nil {
+		return fmt.Errorf("failed to render summary: %s", err)
+	}
+	page.Summary = summary
+	return nil
+}
+
+// htmlParagraph matches a paragraph of rendered HTML.
+var htmlParagraph = regexp.MustCompile(`(?s)<p>.*?</p>`)
+
+// renderPage converts a page with a renderer, setting its HTML,
+// its summary, the summary field or else its first n paragraphs,
+// and its word count.
+func (s *Site) renderPage(r Renderer, page *Page, n int) error {
+	out, err := r.Render(page, page.Text)
+	if err != nil {
+		return fmt.Errorf("failed to render page: %s", err)
+	}
+	page.HTML = template.HTML(out)
+	page.WordCount = len(strings.Fields(plainify(string(out))))
+	page.ReadingTime = readingTime(page.WordCount)
+	if summary := page.metaString("summary"); summary != "" {
+		page.Summary, err = s.markdownify(summary)
+		return err
+	}
+	page.Summary = template.HTML(bytes.Join(htmlParagraph.FindAll(out, n), []byte("\n")))
+	return nil
+}
+
+// asciidocMeta reads the header of an AsciiDoc document: the
+// = Title line and the :name: value attributes that follow,
+// :revdate: as the date. The header is kept in the text, as
+// its attributes affect the rendering.
+func asciidocMeta(text []byte) (map[string]interface{}, []byte, error) {
+	meta := make(map[string]interface{})
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "//") {
+			continue
+		}
+		if line == "" {
+			if len(meta) > 0 {
+				break
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "= ") && len(meta) == 0 {
+			meta["title"] = strings.TrimSpace(line[2:])
+			continue
+		}
+		// the author and revision lines
+		if !strings.HasPrefix(line, ":") && len(meta) == 1 && meta["title"] != nil {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 || parts[0] != "" || parts[1] == "" {
+			break
+		}
+		name, value := parts[1], strings.TrimSpace(parts[2])
+		if name == "revdate" {
+			name = "date"
+		}
+		meta[name] = value
+	}
+	if len(meta) == 0 {
+		return nil, text, nil
+	}
+	return meta, text, nil
+}
+
+// orgDate matches the date of an Org timestamp, <2006-01-02 Mon>.
+var orgDate = regexp.MustCompile(`^[<\[](\d{4}-\d{2}-\d{2})(?: \w+)?(?: (\d{2}:\d{2}))?[>\]]$`)
+
+// orgMeta reads the #+KEYWORD: value lines at the top of an Org
+// document, lowercased, #+FILETAGS as the tags and the timestamp of
+// #+DATE as the date. Org renderers read them too, so they are kept
+// in the text.
+func orgMeta(text []byte) (map[string]interface{}, []byte, error) {
+	meta := make(map[string]interface{})
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "# ") {
+			continue
+		}
+		if !strings.HasPrefix(line, "#+") {
+			break
+		}
+		parts := strings.SplitN(line[2:], ":", 2)
+		if len(parts) != 2 || strings.ContainsAny(parts[0], " \t") {
+			break
+		}
+		name, value := strings.ToLower(parts[0]), strings.TrimSpace(parts[1])
+		switch name {
+		case "date":
+			if m := orgDate.FindStringSubmatch(value); m != nil {
+				value = strings.TrimSpace(m[1] + " " + m[2])
+				if m[2] != "" {
+					value += ":00"
+				}
+			}
+		case "filetags":
+			var tags []interface{}
+			for _, tag := range strings.Split(value, ":") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					tags = append(tags, tag)
+				}
+			}
+			meta["tags"] = tags
+			continue
+		}
+		meta[name] = value
+	}
+	if len(meta) == 0 {
+		return nil, text, nil
+	}
+	return meta, text, nil
+}
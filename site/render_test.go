@@ -0,0 +1,52 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCommandRendererDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	page := &Page{AbsPath: filepath.Join(dir, "posts", "a.adoc")}
+	if err := os.MkdirAll(filepath.Join(dir, "posts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newSite(os.DirFS(dir), dir, true, nil, "", Config{})
+	out, err := s.commandRenderer("pwd")(page, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := filepath.EvalSymlinks(strings.TrimSpace(string(out))); got != mustEvalSymlinks(t, filepath.Join(dir, "posts")) {
+		t.Errorf("on disk: ran in %s, want the page's directory", got)
+	}
+
+	// a site that isn't on disk has no directory to run in
+	s = newSite(fstest.MapFS{}, dir, false, nil, "", Config{})
+	out, err = s.commandRenderer("pwd")(page, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.TrimSpace(string(out))
+	if strings.HasPrefix(got, dir) {
+		t.Errorf("not on disk: ran in %s, under the site path", got)
+	}
+	if _, err := os.Stat(got); !os.IsNotExist(err) {
+		t.Errorf("not on disk: %s was left behind", got)
+	}
+}
+
+func mustEvalSymlinks(t *testing.T, path string) string {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,87 @@
+package site
+
+import (
+	"encoding/xml"
+	"path/filepath"
+	"time"
+)
+
+// RSS 2.0, https://www.rssboard.org/rss-specification
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          rssSelf   `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+// rssSelf is the atom:link to the feed itself RSS validators expect.
+type rssSelf struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+// writeRSSFeed writes an RSS feed of the pages, their content
+// as the description of each item. RSS authors are email
+// addresses, so the names of the authors are left out.
+func (s *Site) writeRSSFeed(outDir string, cfg Config, info feedInfo, pages Pages) {
+	feed := rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       info.title,
+			Link:        cfg.absURL(info.dir),
+			Description: info.title,
+			Self: rssSelf{
+				Href: cfg.absURL(info.dir + info.file),
+				Rel:  "self",
+				Type: "application/rss+xml",
+			},
+		},
+	}
+
+	var updated time.Time
+	for _, page := range pages {
+		date, _ := page.date()
+		if page.updated().After(updated) {
+			updated = page.updated()
+		}
+		item := rssItem{
+			Title:       page.feedTitle(),
+			Link:        cfg.absURL(page.Url),
+			GUID:        cfg.absURL(page.Url),
+			PubDate:     date.Format(time.RFC1123Z),
+			Categories:  page.Tags,
+			Description: string(page.content()),
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+	if !updated.IsZero() {
+		feed.Channel.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		fatal("failed to render feed:", err)
+	}
+	s.writeFile(filepath.Join(outDir, filepath.FromSlash(info.dir), info.file), append([]byte(xml.Header), body...))
+}
@@ -0,0 +1,294 @@
+package site
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Client makes the requests of an S3 deploy, signed with
+// the credentials of AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+// and AWS_SESSION_TOKEN, addressing the bucket by path so that
+// S3-compatible services work too.
+type s3Client struct {
+	endpoint string
+	bucket   string
+	region   string
+	key      string
+	secret   string
+	token    string
+	client   *http.Client
+}
+
+func newS3Client(target DeployTarget) *s3Client {
+	c := &s3Client{
+		endpoint: strings.TrimSuffix(target.Endpoint, "/"),
+		bucket:   target.Bucket,
+		region:   target.Region,
+		key:      os.Getenv("AWS_ACCESS_KEY_ID"),
+		secret:   os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:    os.Getenv("AWS_SESSION_TOKEN"),
+		client:   &http.Client{Timeout: 5 * time.Minute},
+	}
+	if c.bucket == "" {
+		fatal("s3 deploy target without bucket")
+	}
+	if c.region == "" {
+		c.region = "us-east-1"
+	}
+	if c.endpoint == "" {
+		c.endpoint = "https://s3." + c.region + ".amazonaws.com"
+	}
+	if c.key == "" || c.secret == "" {
+		fatal("s3 deploy needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return c
+}
+
+// deployS3 uploads the files of outDir that differ from those
+// of the bucket, with their content type and cache control.
+func (s *Site) deployS3(outDir string, target DeployTarget, dryRun bool) {
+	c := newS3Client(target)
+	prefix := strings.Trim(target.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	remote, err := c.list(prefix)
+	if err != nil {
+		fatalIO("failed to list bucket:", err)
+	}
+	rules := make([]ignoreRules, len(target.CacheControl))
+	for i, rule := range target.CacheControl {
+		rules[i] = parseIgnoreRules([]string{rule.Pattern})
+	}
+
+	local := make(map[string]bool)
+	for _, file := range deployFiles(outDir) {
+		key := prefix + file
+		local[key] = true
+		body, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(file)))
+		if err != nil {
+			fatalIO("failed to read output:", err)
+		}
+		sum := md5.Sum(body)
+		if remote[key] == hex.EncodeToString(sum[:]) {
+			s.logDebug("= %s", key)
+			continue
+		}
+		s.logf("+ %s", key)
+		if dryRun {
+			continue
+		}
+		header := make(http.Header)
+		contentType := mime.TypeByExtension(path.Ext(file))
+		if contentType == "" {
+			contentType = http.DetectContentType(body)
+		}
+		header.Set("Content-Type", contentType)
+		for i, rule := range rules {
+			if rule.match(file, false) {
+				header.Set("Cache-Control", target.CacheControl[i].Value)
+				break
+			}
+		}
+		if err := c.do("PUT", key, nil, body, header); err != nil {
+			fatalIO(fmt.Sprintf("failed to upload %s: %s", key, err))
+		}
+	}
+	if !target.Delete {
+		return
+	}
+	keys := make([]string, 0, len(remote))
+	for key := range remote {
+		if !local[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s.logf("- %s", key)
+		if dryRun {
+			continue
+		}
+		if err := c.do("DELETE", key, nil, nil, nil); err != nil {
+			fatalIO(fmt.Sprintf("failed to delete %s: %s", key, err))
+		}
+	}
+}
+
+// list returns the ETags, the MD5 of the content of files uploaded
+// in one part, of the objects whose key starts with prefix.
+func (c *s3Client) list(prefix string) (map[string]string, error) {
+	etags := make(map[string]string)
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		var result struct {
+			Contents []struct {
+				Key  string
+				ETag string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if err := c.get("", query, &result); err != nil {
+			return nil, err
+		}
+		for _, object := range result.Contents {
+			etags[object.Key] = strings.Trim(object.ETag, `"`)
+		}
+		if !result.IsTruncated {
+			return etags, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// get requests key, the bucket itself if empty,
+// and decodes the XML response into v.
+func (c *s3Client) get(key string, query url.Values, v interface{}) error {
+	resp, err := c.request("GET", key, query, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return xml.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *s3Client) do(method, key string, query url.Values, body []byte, header http.Header) error {
+	resp, err := c.request(method, key, query, body, header)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// request sends a request signed with AWS Signature Version 4,
+// failing on error responses.
+func (c *s3Client) request(method, key string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	uri := "/" + c.bucket
+	if key != "" {
+		uri += "/" + key
+	}
+	u, err := url.Parse(c.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = uri
+	u.RawPath = s3Escape(uri)
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	c.sign(req, body, time.Now().UTC())
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var e struct {
+			Code    string
+			Message string
+		}
+		data, _ := io.ReadAll(resp.Body)
+		if xml.Unmarshal(data, &e) == nil && e.Code != "" {
+			return nil, fmt.Errorf("%s: %s", e.Code, e.Message)
+		}
+		return nil, fmt.Errorf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// sign adds the Authorization header of AWS Signature Version 4,
+// signing the host and x-amz-* headers.
+func (c *s3Client) sign(req *http.Request, body []byte, now time.Time) {
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.token != "" {
+		req.Header.Set("X-Amz-Security-Token", c.token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := now.Format("20060102") + "/" + c.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	signingKey := []byte("AWS4" + c.secret)
+	for _, part := range []string{now.Format("20060102"), c.region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.key, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape escapes a path as S3 signs it: every byte but
+// the unreserved characters and slashes.
+func s3Escape(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		ch := p[i]
+		if 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' || ch == '/' {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
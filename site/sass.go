@@ -0,0 +1,55 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// isSass reports whether a file is a Sass stylesheet, compiled to CSS.
+func isSass(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".scss" || ext == ".sass"
+}
+
+// isSassPartial reports whether a file is a Sass partial, only
+// imported by other stylesheets and not written to the output.
+func isSassPartial(name string) bool {
+	return isSass(name) && strings.HasPrefix(filepath.Base(name), "_")
+}
+
+// assetName returns the output path of a static file,
+// css/site.css for css/site.scss.
+func assetName(relpath string) string {
+	if isSass(relpath) {
+		return strings.TrimSuffix(relpath, filepath.Ext(relpath)) + ".css"
+	}
+	return relpath
+}
+
+// readAsset returns the content of a static file,
+// compiling Sass stylesheets with the sass command,
+// embedding source maps in dev mode.
+func (s *Site) readAsset(path string) ([]byte, error) {
+	if !isSass(path) {
+		return s.readSource(path)
+	}
+	path, err := s.diskPath(path)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"--no-source-map"}
+	if s.cfg.Dev {
+		args = []string{"--embed-source-map", "--embed-sources"}
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("sass", append(args, path)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s\n%s", path, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
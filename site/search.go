@@ -0,0 +1,69 @@
+package site
+
+import (
+	"encoding/json"
+	"path/filepath"
+)
+
+// SearchConfig controls the search index.
+type SearchConfig struct {
+	Enabled bool `toml:"enabled" yaml:"enabled"`
+	// Fields are the page fields included in the index,
+	// see searchFields.
+	Fields []string `toml:"fields" yaml:"fields"`
+}
+
+// searchFields returns the value of each field that can be
+// put in the search index.
+var searchFields = map[string]func(Page) interface{}{
+	"title": func(p Page) interface{} { return p.metaString("title") },
+	"url":   func(p Page) interface{} { return p.Url },
+	"tags": func(p Page) interface{} {
+		if p.Tags == nil {
+			return []string{}
+		}
+		return p.Tags
+	},
+	"section": func(p Page) interface{} { return p.Section },
+	"lang":    func(p Page) interface{} { return p.Lang },
+	"summary": func(p Page) interface{} { return plainify(string(p.Summary)) },
+	"content": func(p Page) interface{} { return plainify(string(p.content())) },
+	"date": func(p Page) interface{} {
+		if date, err := p.date(); err == nil {
+			return date.Format("2006-01-02")
+		}
+		return nil
+	},
+}
+
+// writeSearch writes search.json, an array with an object of
+// the configured fields for every page, for client-side search
+// libraries. Pages can opt out with `search_exclude: true`.
+func (s *Site) writeSearch(outDir string, cfg Config, pages Pages) {
+	if !cfg.Search.Enabled {
+		return
+	}
+	for _, field := range cfg.Search.Fields {
+		if searchFields[field] == nil {
+			fatal("unknown search field: ", field)
+		}
+	}
+
+	index := make([]map[string]interface{}, 0, len(pages))
+	for _, page := range pages {
+		if page.Meta["search_exclude"] == true || page.isNotFound() {
+			continue
+		}
+		entry := make(map[string]interface{}, len(cfg.Search.Fields))
+		for _, field := range cfg.Search.Fields {
+			entry[field] = searchFields[field](page)
+		}
+		index = append(index, entry)
+	}
+
+	body, err := json.Marshal(index)
+	if err != nil {
+		fatal("failed to render search index:", err)
+	}
+	s.writeFile(filepath.Join(outDir, "search.json"), body)
+}
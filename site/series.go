@@ -0,0 +1,111 @@
+package site
+
+import (
+	"bytes"
+	"html/template"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Series is a series of pages sharing a series front matter field.
+type Series struct {
+	Name string
+	// Url is the url of the series page, if enabled.
+	Url string
+	// Pages are the parts of the series, oldest first.
+	Pages []*Page
+	// Part is the position of the page in the series, from 1,
+	// and Prev and Next the parts before and after it.
+	Part int
+	Prev *Page
+	Next *Page
+}
+
+// seriesURL returns the url of the page of a series.
+func seriesURL(name string) string {
+	return "series/" + slugify(name) + "/"
+}
+
+// linkSeries sets the Series of the pages in a series, ordered by
+// date. Like the Prev/Next links, the parts point into the pages slice.
+func linkSeries(pages Pages, cfg Config) []*Series {
+	byName := make(map[string]*Series)
+	var all []*Series
+	// the pages are sorted newest first
+	for i := len(pages) - 1; i >= 0; i-- {
+		page := &pages[i]
+		name := page.metaString("series")
+		if name == "" {
+			continue
+		}
+		key := page.Lang + "/" + name
+		series, ok := byName[key]
+		if !ok {
+			series = &Series{Name: name}
+			if cfg.SeriesPages {
+				series.Url = seriesURL(name)
+				if page.Lang != "" && page.Lang != cfg.DefaultLanguage {
+					series.Url = page.Lang + "/" + series.Url
+				}
+			}
+			byName[key] = series
+			all = append(all, series)
+		}
+		series.Pages = append(series.Pages, page)
+	}
+	for _, series := range all {
+		for i, page := range series.Pages {
+			part := *series
+			part.Part = i + 1
+			if i > 0 {
+				part.Prev = series.Pages[i-1]
+			}
+			if i < len(series.Pages)-1 {
+				part.Next = series.Pages[i+1]
+			}
+			page.Series = &part
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if name, other := strings.ToLower(all[i].Name), strings.ToLower(all[j].Name); name != other {
+			return name < other
+		}
+		// the same series in other languages
+		if lang, other := all[i].Pages[0].Lang, all[j].Pages[0].Lang; lang != other {
+			return lang < other
+		}
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
+
+// writeSeries renders the list of series to series/index.html
+// and the parts of each series to series/<series>/index.html.
+func (s *Site) writeSeries(outDir string, cfg Config, tmpl *template.Template, all []*Series, pages Pages, tags map[string]Pages, data map[string]interface{}) {
+	if !cfg.SeriesPages || len(all) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	renderSeries := func(url, title string, series *Series, pages Pages) {
+		page := Page{
+			Meta: map[string]interface{}{"title": title},
+			Url:  url,
+		}
+		body := s.render(&buf, tmpl, map[string]interface{}{
+			"Page":      page,
+			"Pages":     pages,
+			"Series":    series,
+			"AllSeries": all,
+			"Tags":      tags,
+			"Site":      cfg,
+			"Data":      data,
+		})
+		s.writeFile(filepath.Join(outDir, filepath.FromSlash(url), "index.html"), body)
+	}
+
+	renderSeries("series/", "Series", nil, pages)
+	for _, series := range all {
+		renderSeries(series.Url, series.Name, series, pages)
+	}
+}
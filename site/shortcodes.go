@@ -0,0 +1,246 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"text/template/parse"
+)
+
+// shortcodesDir holds the shortcode templates, named after the file.
+const shortcodesDir = "shortcodes"
+
+// defaultShortcodes are available unless the site or theme
+// defines a shortcode of the same name.
+var defaultShortcodes = map[string]string{
+	"youtube": `<div class="video"><iframe src="https://www.youtube-nocookie.com/embed/{{ .Get 0 }}"` +
+		` allowfullscreen loading="lazy" title="{{ or (.Get "title") "YouTube video" }}"></iframe></div>`,
+	"figure": `{{ $src := or (.Get "src") (.Get 0) }}<figure><picture>{{ sources $src }}<img src="{{ $src }}"` +
+		`{{ with srcset $src }} srcset="{{ . }}"{{ end }} alt="{{ or (.Get "alt") (.Get "caption") (.Get 1) }}"></picture>` +
+		`{{ with or (.Get "caption") (.Get 1) }}<figcaption>{{ markdownify . }}</figcaption>{{ end }}</figure>`,
+}
+
+// Shortcode is the data passed to shortcode templates.
+type Shortcode struct {
+	Name string
+	// Args are the positional arguments and Params the named ones.
+	Args   []string
+	Params map[string]string
+	// Inner is the text between the opening and closing tags
+	// of a paired shortcode, with its own shortcodes expanded.
+	Inner string
+	Page  *Page
+}
+
+// Get returns the positional argument at an int index
+// or the named argument of a string key, or "" if unset.
+func (s Shortcode) Get(key interface{}) string {
+	switch key := key.(type) {
+	case int:
+		if key >= 0 && key < len(s.Args) {
+			return s.Args[key]
+		}
+	case string:
+		return s.Params[key]
+	}
+	return ""
+}
+
+// readShortcodes reads the shortcode templates of the given dirs,
+// along with the partials.
+func (s *Site) readShortcodes(dirs []string, partials []tmplFile) (map[string]*template.Template, error) {
+	files := []tmplFile{}
+	for name, text := range defaultShortcodes {
+		file := tmplFile{name: name, text: text}
+		s.addTmplSource(shortcodesDir+"/"+name, file)
+		files = append(files, file)
+	}
+	files = append(files, s.readTmplFiles(dirs, shortcodesDir)...)
+
+	shortcodes := make(map[string]*template.Template)
+	for _, file := range files {
+		// named after the file, to tell them from the partials in errors
+		tmpl := template.New(shortcodesDir + "/" + file.name).Funcs(s.funcs)
+		for _, partial := range partials {
+			if _, err := tmpl.New(partial.name).Parse(partial.text); err != nil {
+				return nil, s.locateTmplError(err)
+			}
+		}
+		if _, err := tmpl.Parse(file.text); err != nil {
+			return nil, s.locateTmplError(err)
+		}
+		shortcodes[file.name] = tmpl
+	}
+	return shortcodes, nil
+}
+
+// expandShortcodes replaces {{< name args >}} and paired
+// {{< name args >}}...{{< /name >}} tags in the text with the output
+// of their templates. As in Hugo, the shortcodes whose template uses
+// .Inner are paired, the others standing alone, and {{< name />}}
+// stands alone whatever its template. {{</* name */>}} is left in
+// the text as {{< name >}}.
+func (s *Site) expandShortcodes(shortcodes map[string]*template.Template, text []byte, page *Page) ([]byte, error) {
+	var out bytes.Buffer
+	for {
+		start, end, tag := nextTag(text)
+		if start == -1 {
+			out.Write(text)
+			return out.Bytes(), nil
+		}
+		out.Write(text[:start])
+		text = text[end:]
+
+		if isEscapedTag(tag) {
+			out.WriteString("{{< " + strings.TrimSpace(tag[2:len(tag)-2]) + " >}}")
+			continue
+		}
+		selfClosed := strings.HasSuffix(tag, "/")
+		if selfClosed {
+			tag = strings.TrimSpace(strings.TrimSuffix(tag, "/"))
+		}
+		fields, err := splitArgs(tag)
+		if err != nil {
+			return nil, fmt.Errorf("shortcode %q: %s", tag, err)
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("unexpected shortcode %q", tag)
+		}
+		if strings.HasPrefix(fields[0], "/") {
+			if _, ok := shortcodes[fields[0][1:]]; ok {
+				return nil, fmt.Errorf("unexpected shortcode %q: its opening tag is missing, or its template doesn't use .Inner", tag)
+			}
+			return nil, fmt.Errorf("unexpected shortcode %q", tag)
+		}
+		sc := Shortcode{Name: fields[0], Params: make(map[string]string), Page: page}
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, `"`) {
+				arg, _ := strconv.Unquote(field)
+				sc.Args = append(sc.Args, arg)
+			} else if key, value, ok := strings.Cut(field, "="); ok {
+				if unquoted, err := strconv.Unquote(value); err == nil {
+					value = unquoted
+				}
+				sc.Params[key] = value
+			} else {
+				sc.Args = append(sc.Args, field)
+			}
+		}
+		tmpl, ok := shortcodes[sc.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown shortcode %q", sc.Name)
+		}
+
+		// an opening tag without its closing one stands alone
+		if !selfClosed && usesInner(tmpl) {
+			if i, j := closingTag(text, sc.Name); i != -1 {
+				inner, err := s.expandShortcodes(shortcodes, text[:i], page)
+				if err != nil {
+					return nil, err
+				}
+				sc.Inner = string(inner)
+				text = text[j:]
+			}
+		}
+		if err := tmpl.Execute(&out, sc); err != nil {
+			return nil, s.locateTmplError(err)
+		}
+	}
+}
+
+// nextTag returns the start and end of the next shortcode tag
+// in the text and the text between its delimiters, trimmed,
+// or -1 if there's none.
+func nextTag(text []byte) (int, int, string) {
+	start := bytes.Index(text, []byte("{{<"))
+	if start == -1 {
+		return -1, -1, ""
+	}
+	end := bytes.Index(text[start:], []byte(">}}"))
+	if end == -1 {
+		return -1, -1, ""
+	}
+	end += start + 3
+	return start, end, strings.TrimSpace(string(text[start+3 : end-3]))
+}
+
+// isEscapedTag reports whether the tag is {{</* name */>}}.
+func isEscapedTag(tag string) bool {
+	return len(tag) >= 4 && strings.HasPrefix(tag, "/*") && strings.HasSuffix(tag, "*/")
+}
+
+// closingTag returns the start and end of the {{< /name >}} tag
+// closing the shortcode opened before the text, skipping the
+// shortcodes of the same name nested in it, or -1 if there's none.
+func closingTag(text []byte, name string) (int, int) {
+	depth := 1
+	for offset := 0; ; {
+		start, end, tag := nextTag(text[offset:])
+		if start == -1 {
+			return -1, -1
+		}
+		start, end = start+offset, end+offset
+		offset = end
+		if isEscapedTag(tag) || strings.HasSuffix(tag, "/") {
+			continue
+		}
+		fields, err := splitArgs(tag)
+		if err != nil || len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case name:
+			depth++
+		case "/" + name:
+			if depth--; depth == 0 {
+				return start, end
+			}
+		}
+	}
+}
+
+// usesInner reports whether the shortcode template uses .Inner,
+// and so is paired.
+func usesInner(tmpl *template.Template) bool {
+	if tmpl.Tree == nil {
+		return false
+	}
+	found := false
+	walkTree(tmpl.Tree.Root, func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.FieldNode:
+			found = found || n.Ident[0] == "Inner"
+		case *parse.VariableNode:
+			found = found || len(n.Ident) > 1 && n.Ident[0] == "$" && n.Ident[1] == "Inner"
+		}
+	})
+	return found
+}
+
+// splitArgs splits the text of a shortcode tag on spaces,
+// keeping double-quoted arguments and values together.
+func splitArgs(s string) ([]string, error) {
+	var fields []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		i := strings.IndexAny(s, " \t\n\"")
+		switch {
+		case i == -1:
+			fields = append(fields, s)
+			s = ""
+		case s[i] != '"':
+			fields = append(fields, s[:i])
+			s = s[i:]
+		default:
+			// a quoted argument, or the quoted value of key="value"
+			quoted, err := strconv.QuotedPrefix(s[i:])
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, s[:i]+quoted)
+			s = s[i+len(quoted):]
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,133 @@
+package site
+
+import (
+	"html/template"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+		err  bool
+	}{
+		{in: "", want: nil},
+		{in: "  name  ", want: []string{"name"}},
+		{in: "name a b", want: []string{"name", "a", "b"}},
+		{in: "name\ta\nb", want: []string{"name", "a", "b"}},
+		{in: `name "a b" c`, want: []string{"name", `"a b"`, "c"}},
+		{in: `name key="a b" k=v`, want: []string{"name", `key="a b"`, "k=v"}},
+		{in: `name "a \"b\""`, want: []string{"name", `"a \"b\""`}},
+		{in: `name "a b`, err: true},
+	}
+	for _, test := range tests {
+		got, err := splitArgs(test.in)
+		if test.err {
+			if err == nil {
+				t.Errorf("splitArgs(%q): expected an error, got %q", test.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitArgs(%q): %s", test.in, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestExpandShortcodes(t *testing.T) {
+	s := newSite(fstest.MapFS{}, ".", false, nil, "", Config{})
+	shortcodes := make(map[string]*template.Template)
+	for name, text := range map[string]string{
+		"args": `[{{ .Get 0 }}|{{ .Get 1 }}|{{ .Get "k" }}]`,
+		"box":  `<box>{{ safeHTML .Inner }}</box>`,
+		"note": `<note{{ with .Get 0 }} {{ . }}{{ end }}>{{ safeHTML $.Inner }}</note>`,
+		"hr":   `<hr>`,
+	} {
+		shortcodes[name] = template.Must(template.New(shortcodesDir + "/" + name).Funcs(s.funcs).Parse(text))
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+		err  bool
+	}{
+		{name: "no shortcodes", in: "a {{ b }} c", want: "a {{ b }} c"},
+		{name: "unterminated tag", in: "a {{< hr", want: "a {{< hr"},
+		{name: "standalone", in: "a {{< hr >}} b", want: "a <hr> b"},
+		{name: "args", in: `{{< args x "y z" k="v w" >}}`, want: "[x|y z|v w]"},
+		{name: "unquoted param", in: `{{< args k=v >}}`, want: "[||v]"},
+		{name: "paired", in: "{{< box >}}a{{< /box >}}", want: "<box>a</box>"},
+		{name: "paired with $.Inner", in: "{{< note x >}}a{{< /note >}}", want: "<note x>a</note>"},
+		{name: "self-closed", in: "{{< box />}}a", want: "<box></box>a"},
+		{
+			name: "nested same name",
+			in:   "{{< box >}}a{{< box >}}b{{< /box >}}c{{< /box >}}",
+			want: "<box>a<box>b</box>c</box>",
+		},
+		{
+			name: "nested other name",
+			in:   "{{< note >}}a{{< box >}}{{< hr >}}{{< /box >}}{{< /note >}}",
+			want: "<note>a<box><hr></box></note>",
+		},
+		{
+			name: "unpaired opener before a pair",
+			in:   "{{< note 1 >}} a {{< note 2 >}}b{{< /note >}}",
+			want: "<note 1></note> a <note 2>b</note>",
+		},
+		{
+			name: "standalone doesn't swallow text",
+			in:   "{{< hr >}}a{{< box >}}b{{< /box >}}",
+			want: "<hr>a<box>b</box>",
+		},
+		{name: "escaped", in: "a {{</* box x */>}} b", want: "a {{< box x >}} b"},
+		{name: "escaped closing tag", in: "{{</* /box */>}}", want: "{{< /box >}}"},
+		{
+			name: "escaped tags inside a pair",
+			in:   "{{< box >}}{{</* box */>}}{{</* /box */>}}{{< /box >}}",
+			want: "<box>{{< box >}}{{< /box >}}</box>",
+		},
+		{name: "unknown", in: "{{< nope >}}", err: true},
+		{name: "empty tag", in: "{{< >}}", err: true},
+		{name: "stray closing tag", in: "a{{< /box >}}", err: true},
+		{name: "closing a standalone", in: "{{< hr >}}{{< /hr >}}", err: true},
+		{name: "unterminated quote", in: `{{< args "x >}}`, err: true},
+	}
+	for _, test := range tests {
+		got, err := s.expandShortcodes(shortcodes, []byte(test.in), &Page{})
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		} else if string(got) != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestUsesInner(t *testing.T) {
+	tests := map[string]bool{
+		`{{ .Inner }}`:                         true,
+		`{{ $.Inner }}`:                        true,
+		`{{ with .Inner }}{{ . }}{{ end }}`:    true,
+		`{{ if .Get 0 }}{{ .Inner }}{{ end }}`: true,
+		`{{ .Inner | printf "%s" }}`:           true,
+		`{{ .Get 0 }}`:                         false,
+		`Inner`:                                false,
+		`{{ .Page.Title }}`:                    false,
+	}
+	for text, want := range tests {
+		tmpl := template.Must(template.New("t").Parse(text))
+		if got := usesInner(tmpl); got != want {
+			t.Errorf("usesInner(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package site builds static sites out of directories of markdown
+// documents, templates and static files, as the marc command does.
+//
+// A Site, returned by New or NewFS, holds the state of its builds,
+// so that several sites may be built at once. Each Site runs one
+// of its methods at a time.
+package site
+
+import (
+	"html/template"
+	"io/fs"
+	"os"
+
+	"github.com/tdewolff/minify/v2"
+)
+
+// Site is a site to build, with its config: its source, a directory
+// or a file system, and its output.
+type Site struct {
+	// The site is read from src, the paths of its files being joined
+	// to dir, the directory of the site if it's on disk. Likewise,
+	// it's written to out with the paths joined to outDir.
+	dir       string
+	src       fs.FS
+	srcOnDisk bool
+	outDir    string
+	out       Output
+	cfg       Config
+	// name is the name of the site in its logs, set by SetName.
+	name string
+
+	// dates are the timezone and date formats of the config,
+	// and funcs the functions of the site's templates.
+	dates *dateConfig
+	funcs template.FuncMap
+	// dryRun is set with -dry-run to build the site without writing
+	// anything, logging the output files that would be created
+	// or changed instead.
+	dryRun bool
+	// minifier minifies the output files if set.
+	minifier *minify.M
+
+	// The state of the current build, set up by build. outputs are
+	// the files of its output, and written the ones it wrote, the
+	// only ones whose links are checked after it.
+	fingerprints fingerprinter
+	includes     includer
+	images       imageProcessor
+	outputs      outputSet
+	written      outputSet
+	stats        buildStats
+	tmplSources  tmplSources
+}
+
+// New returns the site in siteDir, built to outDir
+// with cfg, its config as read by ReadConfig.
+func New(siteDir, outDir string, cfg Config) *Site {
+	return newSite(os.DirFS(siteDir), siteDir, true, DirOutput(outDir), outDir, cfg)
+}
+
+// NewFS returns the site in a file system, such as an embed.FS,
+// built to out. Sass stylesheets, image formats and gitInfo need
+// the site on disk, and image formats the output too.
+func NewFS(src fs.FS, out Output, cfg Config) *Site {
+	return newSite(src, ".", false, out, ".", cfg)
+}
+
+func newSite(src fs.FS, dir string, onDisk bool, out Output, outDir string, cfg Config) *Site {
+	s := &Site{
+		dir:       dir,
+		src:       src,
+		srcOnDisk: onDisk,
+		outDir:    outDir,
+		out:       out,
+		cfg:       cfg,
+		dates:     newDateConfig(cfg),
+		dryRun:    cfg.DryRun,
+		minifier:  newMinifier(cfg.Minify),
+	}
+	s.funcs = s.templateFuncs()
+	return s
+}
+
+// ReadConfig reads the config of the site in siteDir, marc.toml
+// or marc.yaml, returning the defaults if there is none. It is
+// the config of the environment named by MARC_ENV, production
+// if unset.
+func ReadConfig(siteDir string) (cfg Config, err error) {
+	return ReadConfigEnv(siteDir, os.Getenv("MARC_ENV"))
+}
+
+// ReadConfigEnv reads the config of the site in siteDir for
+// the environment env, overridden by its marc.<env>.toml
+// or marc.<env>.yaml if there is one.
+func ReadConfigEnv(siteDir, env string) (cfg Config, err error) {
+	defer recoverError(&err)
+	s := &Site{dir: siteDir, src: os.DirFS(siteDir), srcOnDisk: true}
+	return s.readConfig(siteDir, env), nil
+}
+
+// ReadConfigFS is ReadConfig for a site in a file system.
+func ReadConfigFS(src fs.FS) (cfg Config, err error) {
+	defer recoverError(&err)
+	s := &Site{dir: ".", src: src}
+	return s.readConfig(".", os.Getenv("MARC_ENV")), nil
+}
+
+// Build builds the site to its output.
+func (s *Site) Build() (err error) {
+	defer recoverError(&err)
+	s.build(s.dir, s.outDir, s.cfg)
+	return nil
+}
+
+// Clean removes the output of the site.
+func (s *Site) Clean() (err error) {
+	defer recoverError(&err)
+	if isWithin(s.dir, s.outDir) {
+		fatalf("refusing to remove %s: it contains the site", s.outDir)
+	}
+	s.logf("- %s", s.outDir)
+	if err := os.RemoveAll(s.outDir); err != nil {
+		fatalIO("failed to remove output:", err)
+	}
+	return nil
+}
+
+// CleanStale removes the files of the output that the last build
+// of the site didn't write or leave unchanged, as recorded in the
+// build cache. With dry, it only logs them.
+func (s *Site) CleanStale(dry bool) (err error) {
+	defer recoverError(&err)
+	if isWithin(s.dir, s.outDir) {
+		fatalf("refusing to prune %s: it contains the site", s.outDir)
+	}
+	s.dryRun = dry
+	s.cleanStale(s.outDir)
+	return nil
+}
+
+// Deploy uploads the output of the site to the deploy target of the
+// config named target, or the first one if empty. With dryRun, it
+// only logs the changes it would make.
+func (s *Site) Deploy(target string, dryRun bool) (err error) {
+	defer recoverError(&err)
+	s.deploy(s.dir, s.outDir, s.cfg, target, dryRun)
+	return nil
+}
+
+// Watch calls rebuild whenever something changes in the directory
+// of the site, outside of its output. It returns only if watching
+// fails.
+func (s *Site) Watch(rebuild func()) (err error) {
+	defer recoverError(&err)
+	s.watch(s.dir, s.outDir, rebuild)
+	return nil
+}
+
+// NewPage creates the page at relpath in the site
+// from the archetype of its section.
+func NewPage(siteDir, relpath string) (err error) {
+	defer recoverError(&err)
+	s := &Site{dir: siteDir, src: os.DirFS(siteDir), srcOnDisk: true}
+	s.newPage(siteDir, relpath)
+	return nil
+}
@@ -0,0 +1,123 @@
+package site
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// SitemapConfig controls the splitting of the sitemap of large sites.
+type SitemapConfig struct {
+	// MaxURLs is the number of urls above which sitemap.xml becomes
+	// an index of a sitemap per top-level section, each split again
+	// in files of MaxURLs urls. It is 50000 by default, the limit
+	// of the sitemap protocol.
+	MaxURLs int `toml:"maxURLs" yaml:"maxURLs"`
+	// Split splits the sitemap per section whatever its size.
+	Split bool `toml:"split" yaml:"split"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc      string `xml:"loc"`
+	LastMod  string `xml:"lastmod,omitempty"`
+	Priority string `xml:"priority,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name       `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// maxSitemapURLs is the number of urls a sitemap may list.
+const maxSitemapURLs = 50000
+
+// writeSitemap lists every page in sitemap.xml. Pages can opt out
+// with `sitemap_exclude: true` or set `sitemap_priority`. Above
+// sitemap.maxURLs urls, or with sitemap.split, sitemap.xml is an
+// index of the sitemaps of the sections, sitemap-<section>.xml,
+// the pages of the site root being in sitemap-pages.xml.
+func (s *Site) writeSitemap(outDir string, cfg Config, pages Pages) {
+	if cfg.BaseURL == "" {
+		s.logWarning("skipping sitemap.xml: baseURL is not set")
+		return
+	}
+
+	var urls []sitemapURL
+	sections := make(map[string][]sitemapURL)
+	for _, page := range pages {
+		if page.Meta["sitemap_exclude"] == true || page.isNotFound() {
+			continue
+		}
+		url := sitemapURL{
+			Loc:      cfg.absURL(page.Url),
+			Priority: page.metaString("sitemap_priority"),
+		}
+		if !page.LastMod.IsZero() {
+			url.LastMod = page.LastMod.UTC().Format("2006-01-02")
+		}
+		urls = append(urls, url)
+		section := page.Section
+		if section == "" {
+			section = "pages"
+		}
+		sections[section] = append(sections[section], url)
+	}
+
+	maxURLs := cfg.Sitemap.MaxURLs
+	if maxURLs <= 0 || maxURLs > maxSitemapURLs {
+		maxURLs = maxSitemapURLs
+	}
+	if len(urls) <= maxURLs && !cfg.Sitemap.Split {
+		s.writeSitemapXML(filepath.Join(outDir, "sitemap.xml"), sitemapURLSet{URLs: urls})
+		return
+	}
+
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var index sitemapIndex
+	for _, name := range names {
+		urls := sections[name]
+		for i := 0; i < len(urls); i += maxURLs {
+			end := i + maxURLs
+			if end > len(urls) {
+				end = len(urls)
+			}
+			chunk := urls[i:end]
+			file := "sitemap-" + name + ".xml"
+			if i > 0 {
+				file = fmt.Sprintf("sitemap-%s-%d.xml", name, i/maxURLs+1)
+			}
+			s.writeSitemapXML(filepath.Join(outDir, file), sitemapURLSet{URLs: chunk})
+			entry := sitemapEntry{Loc: cfg.absURL(file)}
+			for _, url := range chunk {
+				if url.LastMod > entry.LastMod {
+					entry.LastMod = url.LastMod
+				}
+			}
+			index.Sitemaps = append(index.Sitemaps, entry)
+		}
+	}
+	s.writeSitemapXML(filepath.Join(outDir, "sitemap.xml"), index)
+}
+
+func (s *Site) writeSitemapXML(path string, v interface{}) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fatal("failed to render sitemap:", err)
+	}
+	s.writeFile(path, append([]byte(xml.Header), body...))
+}
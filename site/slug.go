@@ -0,0 +1,63 @@
+package site
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// transliterations are the latin letters that don't
+// decompose into a base letter and diacritics.
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d",
+	'ð': "d", 'ł': "l", 'þ': "th", 'ı': "i",
+}
+
+// slugify turns an arbitrary string into something usable in urls.
+// Accents are stripped from latin letters, other scripts are kept.
+func slugify(s string) string {
+	stripped, _, err := transform.String(transform.Chain(
+		norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), s)
+	if err == nil {
+		s = stripped
+	}
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if t, ok := transliterations[r]; ok {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteString(t)
+			dash = false
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// slug returns the last segment of the page's url: the slug front
+// matter field, or else its title with fromTitle, or else its file name.
+func (p Page) slug(fromTitle bool) string {
+	if slug := p.metaString("slug"); slug != "" {
+		return slugify(slug)
+	}
+	filename := p.filename()
+	if fromTitle {
+		if title := p.metaString("title"); title != "" {
+			return slugify(title)
+		}
+		return slugify(filename)
+	}
+	return filename
+}
@@ -0,0 +1,28 @@
+package site
+
+import "testing"
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"":                "",
+		"---":             "",
+		"Hello, World!":   "hello-world",
+		"  Ça va?  ":      "ca-va",
+		"naïve café":      "naive-cafe",
+		"Straße":          "strasse",
+		"Łódź":            "lodz",
+		"Æsir and Œuvre":  "aesir-and-oeuvre",
+		"C++ & Go":        "c-go",
+		"2024 recap":      "2024-recap",
+		"snake_case.name": "snake-case-name",
+		"Привет, мир":     "привет-мир",
+		"日本語":             "日本語",
+		"already-a-slug":  "already-a-slug",
+		"tab\tand\nlines": "tab-and-lines",
+	}
+	for in, want := range tests {
+		if got := slugify(in); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
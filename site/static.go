@@ -0,0 +1,16 @@
+package site
+
+import "path/filepath"
+
+// isStatic reports whether a non-markdown file should be copied
+// to the output as is.
+func isStatic(relpath string) bool {
+	name := filepath.Base(relpath)
+	if isHidden(name) {
+		return false
+	}
+	if filepath.Ext(name) == ".tmpl" || isConfigFile(name) || isSassPartial(name) {
+		return false
+	}
+	return true
+}
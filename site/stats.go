@@ -0,0 +1,123 @@
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// slowestPages is the number of pages listed as the slowest to build.
+const slowestPages = 5
+
+// buildStats counts what a build made and times its phases. The time
+// of each phase is summed over the pages, built in parallel, so may
+// exceed the total.
+type buildStats struct {
+	mu        sync.Mutex
+	Pages     int        `json:"pages"`
+	Files     int        `json:"files"`
+	Tags      int        `json:"tags"`
+	Total     seconds    `json:"total"`
+	Read      seconds    `json:"read"`
+	Markdown  seconds    `json:"markdown"`
+	Templates seconds    `json:"templates"`
+	Write     seconds    `json:"write"`
+	Slowest   []pageTime `json:"slowest"`
+	pages     map[string]time.Duration
+}
+
+// pageTime is the time spent converting, rendering and writing a page.
+type pageTime struct {
+	Path string  `json:"path"`
+	Time seconds `json:"time"`
+}
+
+// seconds is a duration written to JSON in seconds.
+type seconds time.Duration
+
+func (s seconds) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(s).Seconds())
+}
+
+func (s seconds) String() string {
+	return time.Duration(s).Round(time.Microsecond * 100).String()
+}
+
+func (s *buildStats) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Read, s.Markdown, s.Templates, s.Write = 0, 0, 0, 0
+	s.pages = make(map[string]time.Duration)
+}
+
+// add adds the time since start to a phase if not nil,
+// and to the page at relpath if not "".
+func (s *buildStats) add(phase *seconds, relpath string, start time.Time) {
+	d := time.Since(start)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if phase != nil {
+		*phase += seconds(d)
+	}
+	if relpath != "" {
+		s.pages[relpath] += d
+	}
+}
+
+// done completes the statistics once the build is over.
+func (s *buildStats) done(pages, files, tags int, total time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Pages, s.Files, s.Tags = pages, files, tags
+	s.Total = seconds(total)
+	s.Slowest = s.Slowest[:0]
+	for relpath, d := range s.pages {
+		s.Slowest = append(s.Slowest, pageTime{Path: relpath, Time: seconds(d)})
+	}
+	sort.Slice(s.Slowest, func(i, j int) bool {
+		if s.Slowest[i].Time != s.Slowest[j].Time {
+			return s.Slowest[i].Time > s.Slowest[j].Time
+		}
+		return s.Slowest[i].Path < s.Slowest[j].Path
+	})
+	if len(s.Slowest) > slowestPages {
+		s.Slowest = s.Slowest[:slowestPages]
+	}
+}
+
+// logStats logs the statistics of the build,
+// as the build event in the JSON logs.
+func (s *Site) logStats() {
+	if verbosity < Normal {
+		return
+	}
+	st := &s.stats
+	if jsonLogs {
+		s.writeEvent(logEvent{Level: "info", Event: "build", Stats: st})
+		return
+	}
+	s.logf("built %d pages, %d files and %d tags in %s", st.Pages, st.Files, st.Tags, st.Total)
+	s.logf("  read %s, markdown %s, templates %s, write %s", st.Read, st.Markdown, st.Templates, st.Write)
+	if len(st.Slowest) > 0 {
+		slowest := make([]string, len(st.Slowest))
+		for i, page := range st.Slowest {
+			slowest[i] = fmt.Sprintf("%s %s", page.Path, page.Time)
+		}
+		s.logf("  slowest pages: %s", strings.Join(slowest, ", "))
+	}
+}
+
+// write writes the statistics as JSON to the file at path.
+func (s *buildStats) write(path string) {
+	text, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		fatal("failed to encode stats:", err)
+	}
+	if err := os.WriteFile(path, append(text, '\n'), 0644); err != nil {
+		fatalIO("failed to write stats:", err)
+	}
+}
@@ -0,0 +1,61 @@
+package site
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+)
+
+// moreMarker ends the summary of a page when on a line of its own.
+var moreMarker = []byte("<!--more-->")
+
+// summarize returns the summary of a page: the summary front matter
+// field, the content before the <!--more--> marker, or else the
+// first n paragraphs.
+func (s *Site) summarize(md goldmark.Markdown, page *Page, doc ast.Node, n int) (template.HTML, error) {
+	if summary := page.metaString("summary"); summary != "" {
+		return s.markdownify(summary)
+	}
+
+	var nodes []ast.Node
+	for node := doc.FirstChild(); node != nil; node = node.NextSibling() {
+		if isMoreMarker(node, page.Text) {
+			return renderNodes(md, page.Text, nodes)
+		}
+		nodes = append(nodes, node)
+	}
+
+	nodes = nil
+	for node := doc.FirstChild(); node != nil && len(nodes) < n; node = node.NextSibling() {
+		if node.Kind() == ast.KindParagraph {
+			nodes = append(nodes, node)
+		}
+	}
+	return renderNodes(md, page.Text, nodes)
+}
+
+func isMoreMarker(node ast.Node, source []byte) bool {
+	if node.Kind() != ast.KindHTMLBlock {
+		return false
+	}
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		if bytes.Equal(bytes.TrimSpace(segment.Value(source)), moreMarker) {
+			return true
+		}
+	}
+	return false
+}
+
+func renderNodes(md goldmark.Markdown, source []byte, nodes []ast.Node) (template.HTML, error) {
+	var buf bytes.Buffer
+	for _, node := range nodes {
+		if err := md.Renderer().Render(&buf, source, node); err != nil {
+			return "", err
+		}
+	}
+	return template.HTML(buf.String()), nil
+}
@@ -0,0 +1,73 @@
+package site
+
+import (
+	"bytes"
+	"html/template"
+	"path/filepath"
+	"sort"
+)
+
+// collectTags maps each tag to the pages having it, keeping the
+// order of pages. Tags written to the same directory, such as Go
+// and go, are one, named as the first page having it spells it.
+func (s *Site) collectTags(pages Pages) map[string]Pages {
+	tags := make(map[string]Pages)
+	names := make(map[string]string)
+	for _, page := range pages {
+		seen := make(map[string]bool, len(page.Tags))
+		for _, tag := range page.Tags {
+			slug := slugify(tag)
+			if slug == "" {
+				s.logWarning("%s: skipping tag %q: it has no letters or digits", page.RelPath, tag)
+				continue
+			}
+			if seen[slug] {
+				continue
+			}
+			seen[slug] = true
+			name, ok := names[slug]
+			if !ok {
+				name = tag
+				names[slug] = tag
+			}
+			tags[name] = append(tags[name], page)
+		}
+	}
+	return tags
+}
+
+// writeTaxonomy renders the tag list to tags/index.html
+// and the pages of each tag to tags/<tag>/index.html.
+func (s *Site) writeTaxonomy(outDir string, cfg Config, tmpl *template.Template, pages Pages, tags map[string]Pages, data map[string]interface{}) {
+	if len(tags) == 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	renderTag := func(url, title, tag string, pages Pages) {
+		page := Page{
+			Meta: map[string]interface{}{"title": title},
+			Url:  url,
+		}
+		body := s.render(&buf, tmpl, map[string]interface{}{
+			"Page":  page,
+			"Pages": pages,
+			"Tag":   tag,
+			"Tags":  tags,
+			"Site":  cfg,
+			"Data":  data,
+		})
+		s.writeFile(filepath.Join(outDir, filepath.FromSlash(url), "index.html"), body)
+	}
+
+	names := make([]string, 0, len(tags))
+	for tag := range tags {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+
+	renderTag("tags/", "Tags", "", pages)
+	for _, tag := range names {
+		renderTag("tags/"+slugify(tag)+"/", tag, tag, tags[tag])
+	}
+}
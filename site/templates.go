@@ -0,0 +1,254 @@
+package site
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed github-markdown.css
+var defaultCSS string
+
+//go:embed github-markdown.tmpl
+var defaultHTML string
+
+//go:embed taxonomy.tmpl
+var defaultTaxonomyHTML string
+
+//go:embed archive.tmpl
+var defaultArchiveHTML string
+
+//go:embed authors.tmpl
+var defaultAuthorsHTML string
+
+//go:embed series.tmpl
+var defaultSeriesHTML string
+
+//go:embed seo.tmpl
+var defaultSEOHTML string
+
+//go:embed indieweb.tmpl
+var defaultIndieWebHTML string
+
+//go:embed hentry.tmpl
+var defaultHEntryHTML string
+
+//go:embed hcard.tmpl
+var defaultHCardHTML string
+
+// defaultPartials are available to all templates unless
+// the site or theme has a partial of the same name.
+var defaultPartials = []tmplFile{
+	{name: "seo", text: defaultSEOHTML},
+	{name: "indieweb", text: defaultIndieWebHTML},
+	{name: "hentry", text: defaultHEntryHTML},
+	{name: "hcard", text: defaultHCardHTML},
+}
+
+var defaultTmpl *template.Template
+var defaultTaxonomyTmpl *template.Template
+var defaultArchiveTmpl *template.Template
+var defaultAuthorsTmpl *template.Template
+var defaultSeriesTmpl *template.Template
+
+func init() {
+	tmplText := strings.Replace(defaultHTML, "STYLE_PLACEHOLDER", defaultCSS, 1)
+	// the functions are bound to each site once cloned by readTmpl
+	tmplBase := template.New("default").Funcs(new(Site).templateFuncs())
+	for _, partial := range defaultPartials {
+		template.Must(tmplBase.New(partial.name).Parse(partial.text))
+	}
+	defaultTmpl = template.Must(tmplBase.Parse(tmplText))
+	defaultTaxonomyTmpl = template.Must(template.Must(defaultTmpl.Clone()).Parse(defaultTaxonomyHTML))
+	defaultArchiveTmpl = template.Must(template.Must(defaultTmpl.Clone()).Parse(defaultArchiveHTML))
+	defaultAuthorsTmpl = template.Must(template.Must(defaultTmpl.Clone()).Parse(defaultAuthorsHTML))
+	defaultSeriesTmpl = template.Must(template.Must(defaultTmpl.Clone()).Parse(defaultSeriesHTML))
+}
+
+// partialsDir holds the templates shared by all page templates.
+const partialsDir = "templates"
+
+type tmplFile struct {
+	name string
+	text string
+	// path is the file of the template, empty for the built-in ones.
+	path string
+}
+
+// readPartials reads every *.tmpl file in the templates directory of
+// the given dirs. Each file is named after its path without extension
+// (e.g. "header" or "nav/menu"). The dirs are in order of precedence,
+// so the partials are returned lowest precedence first to let
+// the later ones override the earlier ones.
+func (s *Site) readPartials(dirs []string) []tmplFile {
+	for _, partial := range defaultPartials {
+		s.addTmplSource(partial.name, partial)
+	}
+	return append(defaultPartials, s.readTmplFiles(dirs, partialsDir)...)
+}
+
+func (s *Site) readTmplFiles(dirs []string, subdir string) []tmplFile {
+	var files []tmplFile
+	for i := len(dirs) - 1; i >= 0; i-- {
+		root := filepath.Join(dirs[i], subdir)
+		err := s.walkSource(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == root {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if d.IsDir() || filepath.Ext(path) != ".tmpl" {
+				return nil
+			}
+			relpath, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			text, err := s.readSource(path)
+			if err != nil {
+				return err
+			}
+			file := tmplFile{
+				name: filepath.ToSlash(strings.TrimSuffix(relpath, ".tmpl")),
+				text: string(text),
+				path: path,
+			}
+			if subdir == partialsDir {
+				s.addTmplSource(file.name, file)
+			} else {
+				s.addTmplSource(subdir+"/"+file.name, file)
+			}
+			files = append(files, file)
+			return nil
+		})
+		if err != nil {
+			fatalIO("failed to read templates:", err)
+		}
+	}
+	return files
+}
+
+// tmplPath returns the file of the named template in the first of
+// the directories that has it, or "" if none does.
+func (s *Site) tmplPath(dirs []string, name string) string {
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if _, err := s.statSource(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// readTmpl reads the named template from the first of the directories
+// that has it, along with the partials, falling back to the given
+// default (with the partials too) if none does.
+func (s *Site) readTmpl(dirs []string, partials []tmplFile, name string, fallback *template.Template) *template.Template {
+	for _, dir := range dirs {
+		tmplPath := filepath.Join(dir, name)
+		tmplText, err := s.readSource(tmplPath)
+
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			fatalIO("failed to read ", err)
+		}
+
+		s.addTmplSource(name, tmplFile{name: name, text: string(tmplText), path: tmplPath})
+		tmpl := template.New(name).Funcs(s.funcs)
+		for _, partial := range partials {
+			if _, err := tmpl.New(partial.name).Parse(partial.text); err != nil {
+				fatalf("failed to parse template: %s", s.locateTmplError(err))
+			}
+		}
+		if _, err := tmpl.Parse(string(tmplText)); err != nil {
+			fatalf("failed to parse template: %s", s.locateTmplError(err))
+		}
+		return tmpl
+	}
+	if fallback == nil {
+		return nil
+	}
+	tmpl := template.Must(fallback.Clone()).Funcs(s.funcs)
+	for _, partial := range partials {
+		if _, err := tmpl.New(partial.name).Parse(partial.text); err != nil {
+			fatalf("failed to parse template: %s", s.locateTmplError(err))
+		}
+	}
+	return tmpl
+}
+
+// tmplSources maps the names of the templates read to their sources,
+// to locate their errors: partials by their own name, shortcodes
+// by their path in the site and page templates by the name read.
+type tmplSources struct {
+	sync.Mutex
+	files map[string]tmplFile
+}
+
+func (s *Site) addTmplSource(name string, file tmplFile) {
+	s.tmplSources.Lock()
+	defer s.tmplSources.Unlock()
+	if s.tmplSources.files == nil {
+		s.tmplSources.files = make(map[string]tmplFile)
+	}
+	s.tmplSources.files[name] = file
+}
+
+// tmplErrorPattern matches the location text/template and
+// html/template put at the start of their errors.
+var tmplErrorPattern = regexp.MustCompile(`(?s)^(?:html/)?template: ?([^:\s]+)(?::(\d+))?(?::(\d+))?: (.*)$`)
+
+// locateTmplError rewrites the location of a template error with the
+// path of the template file, and adds the line of the template it
+// points to, marking the column if known.
+func (s *Site) locateTmplError(err error) error {
+	m := tmplErrorPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	s.tmplSources.Lock()
+	file, ok := s.tmplSources.files[m[1]]
+	s.tmplSources.Unlock()
+	if !ok {
+		return err
+	}
+	where := file.path
+	if where == "" {
+		where = fmt.Sprintf("built-in template %q", m[1])
+	}
+	if m[2] == "" {
+		return fmt.Errorf("%s: %s", where, m[4])
+	}
+	msg := fmt.Sprintf("%s:%s: %s", where, m[2], m[4])
+	if m[3] != "" {
+		msg = fmt.Sprintf("%s:%s:%s: %s", where, m[2], m[3], m[4])
+	}
+	line, _ := strconv.Atoi(m[2])
+	lines := strings.Split(file.text, "\n")
+	if line < 1 || line > len(lines) {
+		return errors.New(msg)
+	}
+	text := strings.TrimRight(lines[line-1], " \t\r")
+	msg += "\n    " + text
+	if col, err := strconv.Atoi(m[3]); err == nil && col < len(text) {
+		indent := strings.Map(func(r rune) rune {
+			if r == '\t' {
+				return r
+			}
+			return ' '
+		}, text[:col])
+		msg += "\n    " + indent + "^"
+	}
+	return errors.New(msg)
+}
@@ -0,0 +1,68 @@
+package site
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// themeDir returns the directory of the site's theme: themes/<name>
+// if the config names one, or the path it gives relative to the site
+// (such as a theme shared by the sites of a workspace), otherwise
+// theme/ if it exists. It returns an empty string if the site has
+// no theme.
+func (s *Site) themeDir(siteDir string, cfg Config) string {
+	if cfg.Theme != "" {
+		dir := filepath.Join(siteDir, "themes", cfg.Theme)
+		if filepath.IsAbs(cfg.Theme) {
+			dir = cfg.Theme
+		} else if strings.ContainsAny(cfg.Theme, "/\\") {
+			dir = filepath.Join(siteDir, cfg.Theme)
+		}
+		if _, err := s.statSource(dir); err != nil {
+			fatal("failed to find theme:", err)
+		}
+		return dir
+	}
+	dir := filepath.Join(siteDir, "theme")
+	if stat, err := s.statSource(dir); err == nil && stat.IsDir() {
+		return dir
+	}
+	return ""
+}
+
+// readTheme collects the static files of the theme, keyed by their
+// path relative to the theme, and its templates.
+func (s *Site) readTheme(dir string) (map[string]string, []string) {
+	assets := make(map[string]string)
+	deps := make([]string, 0)
+	if dir == "" {
+		return assets, deps
+	}
+	err := s.walkSource(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && isHidden(d.Name()) || path == filepath.Join(dir, dataDir) || path == filepath.Join(dir, jekyllDataDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch {
+		case filepath.Ext(path) == ".tmpl" || isSassPartial(path):
+			deps = append(deps, path)
+		case !s.isMarkdown(filepath.Ext(path)) && isStatic(path):
+			relpath, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			assets[assetName(relpath)] = path
+		}
+		return nil
+	})
+	if err != nil {
+		fatalIO("failed to read theme:", err)
+	}
+	return assets, deps
+}
@@ -0,0 +1,83 @@
+package site
+
+import (
+	"html/template"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// TOCEntry is a heading in the page's table of contents.
+type TOCEntry struct {
+	Title    string
+	ID       string
+	Level    int
+	Children TOC
+}
+
+// TOC is the table of contents of a page, built from its headings.
+type TOC []*TOCEntry
+
+// buildTOC collects the headings of the document into a tree.
+// Skipped levels (e.g. h2 followed by h4) are nested directly.
+func buildTOC(doc ast.Node, source []byte) TOC {
+	var toc TOC
+	var stack []*TOCEntry
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		entry := &TOCEntry{
+			Title: string(heading.Text(source)),
+			Level: heading.Level,
+		}
+		if id, ok := heading.AttributeString("id"); ok {
+			if id, ok := id.([]byte); ok {
+				entry.ID = string(id)
+			}
+		}
+
+		for len(stack) > 0 && stack[len(stack)-1].Level >= entry.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			toc = append(toc, entry)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		stack = append(stack, entry)
+		return ast.WalkSkipChildren, nil
+	})
+	return toc
+}
+
+// HTML renders the table of contents as nested lists.
+func (toc TOC) HTML() template.HTML {
+	if len(toc) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	toc.write(&b)
+	return template.HTML(b.String())
+}
+
+func (toc TOC) write(b *strings.Builder) {
+	b.WriteString("<ul>")
+	for _, entry := range toc {
+		b.WriteString("<li>")
+		if entry.ID != "" {
+			b.WriteString(`<a href="#` + template.HTMLEscapeString(entry.ID) + `">`)
+			b.WriteString(template.HTMLEscapeString(entry.Title))
+			b.WriteString("</a>")
+		} else {
+			b.WriteString(template.HTMLEscapeString(entry.Title))
+		}
+		if len(entry.Children) > 0 {
+			entry.Children.write(b)
+		}
+		b.WriteString("</li>")
+	}
+	b.WriteString("</ul>")
+}
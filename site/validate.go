@@ -0,0 +1,166 @@
+package site
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FieldSchema describes a front matter field, its type being one of
+// string, date, list or bool, or any if empty. If Values isn't empty,
+// the field, or each item of a list, must be one of them.
+type FieldSchema struct {
+	Type   string   `toml:"type" yaml:"type"`
+	Values []string `toml:"values" yaml:"values"`
+}
+
+// checkRequired returns a report line for each page, besides the
+// index pages, missing some of the required front matter fields
+// or having them empty.
+func checkRequired(pages Pages, required []string) []string {
+	var report []string
+	for _, page := range pages {
+		if page.isIndex() || page.isNotFound() {
+			continue
+		}
+		var missing []string
+		for _, field := range required {
+			if isEmptyMeta(page.Meta[field]) {
+				missing = append(missing, field)
+			}
+		}
+		if len(missing) > 0 {
+			report = append(report, fmt.Sprintf("%s: missing %s", page.RelPath, strings.Join(missing, ", ")))
+		}
+	}
+	return report
+}
+
+func isEmptyMeta(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// checkSchema returns a report line for each front matter field of
+// the pages not matching the schema, or not declared in it at all.
+// Nothing is checked without a schema.
+func checkSchema(pages Pages, schema map[string]FieldSchema) []string {
+	if len(schema) == 0 {
+		return nil
+	}
+	var report []string
+	for _, page := range pages {
+		keys := make([]string, 0, len(page.Meta))
+		for key := range page.Meta {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			field, ok := schema[key]
+			if !ok {
+				report = append(report, fmt.Sprintf("%s: %s: unknown field", page.RelPath, key))
+				continue
+			}
+			if err := field.check(page.Meta[key], page.dateConfig()); err != nil {
+				report = append(report, fmt.Sprintf("%s: %s: %s", page.RelPath, key, err))
+			}
+		}
+	}
+	return report
+}
+
+func (f FieldSchema) check(value interface{}, dates *dateConfig) error {
+	if value == nil {
+		return nil
+	}
+	var items []interface{}
+	switch f.Type {
+	case "":
+		return nil
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("expected string, got %s", metaType(value))
+		}
+		items = []interface{}{value}
+	case "date":
+		switch v := value.(type) {
+		case time.Time:
+		case string:
+			if _, err := dates.parse(v); err != nil {
+				return fmt.Errorf("expected date, got %q", v)
+			}
+		default:
+			return fmt.Errorf("expected date, got %s", metaType(value))
+		}
+	case "list":
+		list, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected list, got %s", metaType(value))
+		}
+		items = list
+	case "bool":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("expected bool, got %s", metaType(value))
+		}
+	default:
+		return fmt.Errorf("unknown schema type %q", f.Type)
+	}
+	if len(f.Values) == 0 {
+		return nil
+	}
+	for _, item := range items {
+		if !isOneOf(fmt.Sprint(item), f.Values) {
+			return fmt.Errorf("expected one of %s, got %q", strings.Join(f.Values, ", "), fmt.Sprint(item))
+		}
+	}
+	return nil
+}
+
+func isOneOf(value string, values []string) bool {
+	for _, v := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// metaType names the type of a front matter value in reports.
+func metaType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case time.Time:
+		return "date"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	case int, int64, uint64, float64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// validatePages reports the front matter problems of the pages,
+// failing the build in strict mode.
+func (s *Site) validatePages(pages Pages, cfg Config) {
+	report := checkRequired(pages, cfg.Required)
+	report = append(report, checkSchema(pages, cfg.Schema)...)
+	for _, line := range report {
+		s.logWarning("%s", line)
+	}
+	if len(report) > 0 && cfg.Strict {
+		fatalf("%d front matter problems", len(report))
+	}
+}
@@ -0,0 +1,67 @@
+package site
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watch calls rebuild whenever something changes in the site directory.
+// Bursts of events (editors often write a file in several steps)
+// are coalesced into a single rebuild.
+func (s *Site) watch(siteDir, outDir string, rebuild func()) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fatalIO("failed to start watcher:", err)
+	}
+	defer watcher.Close()
+
+	addDirs := func(root string) {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			// unlike the build, this includes themes and archetypes
+			if (path != siteDir && isHidden(d.Name())) || isWithin(path, outDir) {
+				return filepath.SkipDir
+			}
+			if err := watcher.Add(path); err != nil {
+				s.logWarning("failed to watch %s: %s", path, err)
+			}
+			return nil
+		})
+	}
+	addDirs(siteDir)
+	s.logf("watching %s for changes", siteDir)
+
+	const delay = 100 * time.Millisecond
+	timer := time.NewTimer(delay)
+	timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			name := filepath.Base(event.Name)
+			if isWithin(event.Name, outDir) || isHidden(name) || strings.HasSuffix(name, "~") {
+				continue
+			}
+			if event.Op&fsnotify.Create != 0 {
+				addDirs(event.Name)
+			}
+			timer.Reset(delay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			s.logWarning("watcher error: %s", err)
+		case <-timer.C:
+			rebuild()
+		}
+	}
+}
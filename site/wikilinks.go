@@ -0,0 +1,163 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// The wikilinks extension parses [[Target]], [[Target#heading]] and
+// [[Target|label]] links, resolved by resolveWikiLinks to the page
+// with that file name, path or title.
+
+// WikilinkConfig controls the wiki-style links.
+type WikilinkConfig struct {
+	Enabled bool `toml:"enabled" yaml:"enabled"`
+	// Unresolved is what happens to links to missing pages:
+	// "text", the default, renders their label, "warn" also
+	// reports them, and "error" fails the build.
+	Unresolved string `toml:"unresolved" yaml:"unresolved"`
+}
+
+var kindWikiLink = ast.NewNodeKind("WikiLink")
+
+type wikiLinkNode struct {
+	ast.BaseInline
+	Target   string
+	Fragment string
+	Label    string
+}
+
+func (n *wikiLinkNode) Kind() ast.NodeKind { return kindWikiLink }
+
+func (n *wikiLinkNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.Target}, nil)
+}
+
+type wikiLinkParser struct{}
+
+func (p *wikiLinkParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if !bytes.HasPrefix(line, []byte("[[")) {
+		return nil
+	}
+	end := bytes.Index(line, []byte("]]"))
+	if end < 3 {
+		return nil
+	}
+	content := string(line[2:end])
+	target, label, ok := strings.Cut(content, "|")
+	if !ok {
+		label = target
+	}
+	target, fragment, _ := strings.Cut(target, "#")
+	if strings.TrimSpace(target) == "" && fragment == "" {
+		return nil
+	}
+	block.Advance(end + 2)
+	return &wikiLinkNode{
+		Target:   strings.TrimSpace(target),
+		Fragment: strings.TrimSpace(fragment),
+		Label:    strings.TrimSpace(label),
+	}
+}
+
+type wikiLinkRenderer struct{}
+
+func (r *wikiLinkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindWikiLink, r.renderWikiLink)
+}
+
+// renderWikiLink renders the label of links left unresolved.
+func (r *wikiLinkRenderer) renderWikiLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		w.Write(util.EscapeHTML([]byte(node.(*wikiLinkNode).Label)))
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+type wikiLinkExtension struct{}
+
+func (e *wikiLinkExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		// before the link parser
+		parser.WithInlineParsers(util.Prioritized(&wikiLinkParser{}, 199)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&wikiLinkRenderer{}, 500)),
+	)
+}
+
+// wikiTargets maps the lowercased paths without extension, file
+// names and titles of the pages to their urls, for resolveWikiLinks.
+// Paths take precedence over file names, and file names over titles.
+func wikiTargets(pages Pages) map[string]string {
+	targets := make(map[string]string)
+	add := func(key, url string) {
+		key = strings.ToLower(key)
+		if _, ok := targets[key]; !ok && key != "" {
+			targets[key] = url
+		}
+	}
+	for _, page := range pages {
+		relpath := filepath.ToSlash(page.RelPath)
+		add(strings.TrimSuffix(relpath, filepath.Ext(relpath)), page.Url)
+	}
+	for _, page := range pages {
+		add(page.filename(), page.Url)
+	}
+	for _, page := range pages {
+		add(page.metaString("title"), page.Url)
+	}
+	return targets
+}
+
+// resolveWikiLinks turns the wiki links of a page into links to
+// their target pages, [[#heading]] linking within the page.
+func (s *Site) resolveWikiLinks(doc ast.Node, page *Page, targets map[string]string, cfg MarkdownConfig) error {
+	var nodes []*wikiLinkNode
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*wikiLinkNode); ok && entering {
+			nodes = append(nodes, link)
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, n := range nodes {
+		dest := ""
+		if n.Target != "" {
+			url, ok := targets[strings.ToLower(n.Target)]
+			if !ok {
+				switch cfg.Wikilinks.Unresolved {
+				case "error":
+					return fmt.Errorf("link to missing page [[%s]]", n.Target)
+				case "warn":
+					s.logWarning("%s: link to missing page [[%s]]", page.RelPath, n.Target)
+				}
+				continue
+			}
+			dest = s.relURL(url)
+		}
+		if n.Fragment != "" {
+			// the id goldmark gives the heading
+			id := parser.NewContext().IDs().Generate([]byte(n.Fragment), ast.KindHeading)
+			dest += "#" + cfg.HeadingIDPrefix + string(id)
+		}
+		link := ast.NewLink()
+		link.Destination = []byte(dest)
+		link.AppendChild(link, ast.NewString([]byte(n.Label)))
+		n.Parent().ReplaceChild(n.Parent(), n, link)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package site
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+func TestResolveWikiLinks(t *testing.T) {
+	targets := wikiTargets(Pages{
+		{RelPath: "index.md", Url: ""},
+		{RelPath: "notes/go.md", Url: "notes/go/"},
+		{RelPath: "posts/go.md", Url: "posts/go/", Meta: map[string]interface{}{"title": "Learning Go"}},
+		{RelPath: "about.md", Url: "about/", Meta: map[string]interface{}{"title": "About Me"}},
+	})
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{src: "[[about]]", want: []string{"/about/"}},
+		{src: "[[About Me]]", want: []string{"/about/"}},
+		{src: "[[posts/go]]", want: []string{"/posts/go/"}},
+		{src: "[[learning go]]", want: []string{"/posts/go/"}},
+		{src: "[[about|the author]]", want: []string{"/about/"}},
+		{src: "[[about#Contact Info]]", want: []string{"/about/#contact-info"}},
+		{src: "[[#Intro]]", want: []string{"#intro"}},
+		{src: "[[missing]]", want: nil},
+		{src: "[[]]", want: nil},
+	}
+	s := newSite(fstest.MapFS{}, ".", false, nil, "", Config{})
+	md := goldmark.New(goldmark.WithExtensions(&wikiLinkExtension{}))
+	for _, test := range tests {
+		doc := md.Parser().Parse(text.NewReader([]byte(test.src)))
+		err := s.resolveWikiLinks(doc, &Page{RelPath: "index.md"}, targets, MarkdownConfig{Wikilinks: WikilinkConfig{Unresolved: "text"}})
+		if err != nil {
+			t.Errorf("%s: %v", test.src, err)
+			continue
+		}
+		var got []string
+		ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if link, ok := n.(*ast.Link); ok && entering {
+				got = append(got, string(link.Destination))
+			}
+			return ast.WalkContinue, nil
+		})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.src, got, test.want)
+		}
+	}
+
+	doc := md.Parser().Parse(text.NewReader([]byte("[[missing]]")))
+	if err := s.resolveWikiLinks(doc, &Page{RelPath: "index.md"}, targets, MarkdownConfig{Wikilinks: WikilinkConfig{Unresolved: "error"}}); err == nil {
+		t.Error("missing page: got no error, want one")
+	}
+
+	doc = md.Parser().Parse(text.NewReader([]byte("[[about#Contact Info]]")))
+	s.resolveWikiLinks(doc, &Page{RelPath: "index.md"}, targets, MarkdownConfig{HeadingIDPrefix: "h-"})
+	if link := doc.FirstChild().FirstChild().(*ast.Link); string(link.Destination) != "/about/#h-contact-info" {
+		t.Errorf("with a heading id prefix: got %q, want %q", link.Destination, "/about/#h-contact-info")
+	}
+}
+
+func TestWikiTargetsPrecedence(t *testing.T) {
+	targets := wikiTargets(Pages{
+		{RelPath: "a.md", Url: "a/", Meta: map[string]interface{}{"title": "b"}},
+		{RelPath: "b.md", Url: "b/"},
+		{RelPath: "x/b.md", Url: "x/b/"},
+	})
+	want := map[string]string{"a": "a/", "b": "b/", "x/b": "x/b/"}
+	if !reflect.DeepEqual(targets, want) {
+		t.Errorf("got %v, want %v", targets, want)
+	}
+}
@@ -0,0 +1,31 @@
+package site
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// wordsPerMinute is the reading speed used for the reading time.
+const wordsPerMinute = 200
+
+// countWords returns the number of words in the text of a document,
+// code blocks and raw html excluded.
+func countWords(doc ast.Node, source []byte) int {
+	count := 0
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if text, ok := n.(*ast.Text); ok && entering {
+			count += len(bytes.Fields(text.Segment.Value(source)))
+		}
+		return ast.WalkContinue, nil
+	})
+	return count
+}
+
+// readingTime returns the minutes it takes to read n words, at least one.
+func readingTime(n int) int {
+	if n <= wordsPerMinute {
+		return 1
+	}
+	return (n + wordsPerMinute - 1) / wordsPerMinute
+}
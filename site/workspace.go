@@ -0,0 +1,101 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Workspace lists sites built together by one marc build,
+// read from the marc.work.toml or marc.work.yaml of a directory.
+type Workspace struct {
+	Sites []WorkspaceSite `toml:"sites" yaml:"sites"`
+	// Jobs is the number of sites built at once,
+	// the number of CPUs by default.
+	Jobs int `toml:"jobs" yaml:"jobs"`
+}
+
+// WorkspaceSite is a site of a workspace. A site directory may be
+// listed more than once, for variants of the site built for different
+// environments (marc.<env>.yaml) to their own output directories.
+type WorkspaceSite struct {
+	// Dir is the site directory, relative to the workspace.
+	Dir string `toml:"dir" yaml:"dir"`
+	// Env is the environment the site is built for.
+	Env string `toml:"env" yaml:"env"`
+	// Output is the output directory, relative to the workspace,
+	// that of the site's config by default.
+	Output string `toml:"output" yaml:"output"`
+	// Name prefixes the logs of the site's build,
+	// the directory and environment by default.
+	Name string `toml:"name" yaml:"name"`
+}
+
+var workspaceFiles = []string{"marc.work.toml", "marc.work.yaml", "marc.work.yml"}
+
+// ReadWorkspace reads the workspace of dir, returning nil if there is
+// none. The directories of its sites are joined to dir.
+func ReadWorkspace(dir string) (ws *Workspace, err error) {
+	defer recoverError(&err)
+	for _, name := range workspaceFiles {
+		text, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			fatalIO("failed to read workspace: ", err)
+		}
+		ws = &Workspace{}
+		if filepath.Ext(name) == ".toml" {
+			err = toml.Unmarshal(text, ws)
+		} else {
+			err = yaml.Unmarshal(text, ws)
+		}
+		if err != nil {
+			fatalf("failed to parse %s: %s", name, err)
+		}
+		checkWorkspace(dir, ws)
+		return ws, nil
+	}
+	return nil, nil
+}
+
+// checkWorkspace joins the paths of the sites to dir, names them,
+// and fails on sites sharing their output directory.
+func checkWorkspace(dir string, ws *Workspace) {
+	if len(ws.Sites) == 0 {
+		fatal("the workspace lists no sites")
+	}
+	outputs := make(map[string]string)
+	for i := range ws.Sites {
+		s := &ws.Sites[i]
+		if s.Dir == "" {
+			fatalf("site %d of the workspace has no dir", i+1)
+		}
+		if s.Name == "" {
+			s.Name = s.Dir
+			if s.Env != "" {
+				s.Name += " (" + s.Env + ")"
+			}
+		}
+		s.Dir = filepath.Join(dir, s.Dir)
+		out := s.Output
+		if out != "" {
+			s.Output = filepath.Join(dir, s.Output)
+			out = s.Output
+		} else {
+			// the output of the config is only known once read,
+			// so variants without one share the site's
+			out = s.Dir + string(filepath.Separator)
+		}
+		if other, ok := outputs[out]; ok {
+			fatalf("sites %s and %s of the workspace need their own output", other, s.Name)
+		}
+		outputs[out] = s.Name
+	}
+	if ws.Jobs < 0 {
+		fatal("the workspace's jobs can't be negative")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// tlsHosts are the names the development certificate is valid for.
+var tlsHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// devCertificate returns the certificate to serve HTTPS with: the
+// given files if any, or else one for localhost kept in the user's
+// cache directory, made by mkcert if it is installed, so browsers
+// trust it, or else self-signed.
+func devCertificate(certFile, keyFile string) (tls.Certificate, error) {
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return tls.Certificate{}, fmt.Errorf("-cert and -key go together")
+		}
+		return tls.LoadX509KeyPair(certFile, keyFile)
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	dir = filepath.Join(dir, "marc")
+	certFile = filepath.Join(dir, "localhost.pem")
+	keyFile = filepath.Join(dir, "localhost-key.pem")
+	if cert, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil && validCertificate(cert) {
+		return cert, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return tls.Certificate{}, err
+	}
+	if _, err := exec.LookPath("mkcert"); err == nil {
+		args := append([]string{"-cert-file", certFile, "-key-file", keyFile}, tlsHosts...)
+		if output, err := exec.Command("mkcert", args...).CombinedOutput(); err != nil {
+			return tls.Certificate{}, fmt.Errorf("mkcert failed: %s\n%s", err, output)
+		}
+		log.Println("made a certificate for localhost with mkcert")
+	} else {
+		if err := writeSelfSigned(certFile, keyFile); err != nil {
+			return tls.Certificate{}, err
+		}
+		log.Println("made a self-signed certificate for localhost; install mkcert for one browsers trust")
+	}
+	return tls.LoadX509KeyPair(certFile, keyFile)
+}
+
+// validCertificate reports whether the certificate
+// is valid for another day at least and is no CA.
+func validCertificate(cert tls.Certificate) bool {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	// the self-signed certificates of earlier versions were CAs,
+	// able to sign certificates for any site once trusted
+	return err == nil && !leaf.IsCA && time.Now().Add(24*time.Hour).Before(leaf.NotAfter)
+}
+
+// writeSelfSigned writes a self-signed leaf certificate for
+// the tlsHosts, valid for a year, and its key. It is no CA, so
+// accepting it in a browser trusts it for those hosts only.
+func writeSelfSigned(certFile, keyFile string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"marc development"}, CommonName: "localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+	}
+	for _, host := range tlsHosts {
+		if ip := net.ParseIP(host); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/nkanaev/marc/site"
+)
+
+// siteFlags are the build flags set per site of a workspace,
+// or naming files each build would write over.
+var siteFlags = []string{"o", "output", "watch", "stats"}
+
+// buildWorkspace builds the sites of the workspace with the flags
+// given, jobs of them at once. Variants of a site directory are built
+// one after the other, sharing the copies of its remote sources.
+func buildWorkspace(ws *site.Workspace, flags *flag.FlagSet, bf buildFlags) {
+	var bad error
+	flags.Visit(func(f *flag.Flag) {
+		for _, name := range siteFlags {
+			if f.Name == name {
+				bad = fmt.Errorf("-%s can't be used to build a workspace", name)
+			}
+		}
+	})
+	if bad != nil {
+		fmt.Fprintln(os.Stderr, bad)
+		os.Exit(exitUsage)
+	}
+	bf.setLogging()
+
+	// the variants of a site directory are built by the same job
+	var dirs []string
+	variants := make(map[string][]site.WorkspaceSite)
+	for _, s := range ws.Sites {
+		if variants[s.Dir] == nil {
+			dirs = append(dirs, s.Dir)
+		}
+		variants[s.Dir] = append(variants[s.Dir], s)
+	}
+	jobs := ws.Jobs
+	if jobs == 0 {
+		jobs = runtime.NumCPU()
+	}
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+		code   int
+	)
+	stopProfile := bf.profile()
+	sem := make(chan struct{}, jobs)
+	for _, dir := range dirs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(sites []site.WorkspaceSite) {
+			defer func() { <-sem; wg.Done() }()
+			for _, s := range sites {
+				c := buildSite(s, bf)
+				if c == 0 {
+					continue
+				}
+				mu.Lock()
+				failed++
+				if c > code {
+					code = c
+				}
+				mu.Unlock()
+			}
+		}(variants[dir])
+	}
+	wg.Wait()
+	stopProfile()
+	if failed > 0 {
+		site.LogError(fmt.Errorf("%d of %d sites failed to build", failed, len(ws.Sites)))
+		os.Exit(code)
+	}
+}
+
+// buildSite builds a site of a workspace, its logs named after
+// the site, and returns the exit code of its build.
+func buildSite(ws site.WorkspaceSite, bf buildFlags) int {
+	env := ws.Env
+	if env == "" {
+		env = bf.environment()
+	}
+	cfg, err := site.ReadConfigEnv(ws.Dir, env)
+	if err != nil {
+		site.LogError(fmt.Errorf("[%s] %w", ws.Name, err))
+		return exitCode(err)
+	}
+	bf.apply(&cfg)
+	s := site.New(ws.Dir, site.OutputDir(ws.Dir, ws.Output, cfg), cfg)
+	s.SetName(ws.Name)
+	if err := s.Build(); err != nil {
+		s.LogError(err)
+		return exitCode(err)
+	}
+	return 0
+}